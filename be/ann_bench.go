@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"pbkk-quizlit-backend/internal/services"
+)
+
+// annBenchOptions sizes the synthetic corpus used by runANNBench
+type annBenchOptions struct {
+	Items    int
+	Dims     int
+	Queries  int
+	K        int
+	EfSearch int
+}
+
+// annBenchResult is the timing of one store over the benchmark queries
+type annBenchResult struct {
+	build     time.Duration
+	latencies []time.Duration
+	results   [][]string
+}
+
+// validate rejects sizes the benchmark cannot run with
+func (o annBenchOptions) validate() error {
+	switch {
+	case o.Items < 1:
+		return fmt.Errorf("-ann-items must be at least 1, got %d", o.Items)
+	case o.Dims < 1:
+		return fmt.Errorf("-ann-dims must be at least 1, got %d", o.Dims)
+	case o.Queries < 1:
+		return fmt.Errorf("-ann-queries must be at least 1, got %d", o.Queries)
+	case o.K < 1:
+		return fmt.Errorf("-ann-k must be at least 1, got %d", o.K)
+	case o.EfSearch < 0:
+		return fmt.Errorf("-ann-ef must not be negative, got %d", o.EfSearch)
+	}
+	return nil
+}
+
+// runANNBench compares the HNSW store against the brute-force memory store
+// on clustered random embeddings, reporting build time, query latency and
+// recall@k of the approximate results against the exact ones, both over the
+// whole corpus and filtered to a single user.
+func runANNBench(opts annBenchOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+
+	fmt.Println("ANN Benchmark")
+	fmt.Println("=============")
+	fmt.Printf("Items: %d  Dims: %d  Queries: %d  k: %d  efSearch: %d\n\n", opts.Items, opts.Dims, opts.Queries, opts.K, opts.EfSearch)
+
+	// Embeddings cluster around topics, as chunks of related documents do
+	const clusters = 64
+	const users = 10
+	centers := make([][]float64, clusters)
+	for i := range centers {
+		centers[i] = randomVector(rng, opts.Dims, nil, 1)
+	}
+	items := make([]services.VectorItem, opts.Items)
+	for i := range items {
+		items[i] = services.VectorItem{
+			ID:         fmt.Sprintf("doc-%d:%d", i/50, i%50),
+			DocumentID: fmt.Sprintf("doc-%d", i/50),
+			UserID:     fmt.Sprintf("user-%d", (i/50)%users),
+			ChunkIndex: i % 50,
+			Embedding:  randomVector(rng, opts.Dims, centers[rng.Intn(clusters)], 0.6),
+		}
+	}
+	queries := make([][]float64, opts.Queries)
+	for i := range queries {
+		queries[i] = randomVector(rng, opts.Dims, centers[rng.Intn(clusters)], 0.6)
+	}
+
+	exact := services.NewMemoryVectorStore()
+	approx := services.NewHNSWVectorStore(0, 0, opts.EfSearch)
+
+	for _, filter := range []services.VectorFilter{{}, {UserID: "user-3"}} {
+		label := "unfiltered"
+		if filter.UserID != "" {
+			label = "filtered to one user (~10% of items)"
+		}
+
+		exactRes := benchStore(ctx, exact, items, queries, opts.K, filter)
+		approxRes := benchStore(ctx, approx, items, queries, opts.K, filter)
+
+		fmt.Printf("%s\n", label)
+		printBenchLine("brute force", exactRes, 1)
+		printBenchLine("hnsw", approxRes, recallAtK(exactRes.results, approxRes.results))
+		fmt.Println()
+
+		// Only the first pass measures building the stores
+		items = nil
+	}
+	return nil
+}
+
+// benchStore upserts items (when given) and runs every query against store
+func benchStore(ctx context.Context, store services.VectorStore, items []services.VectorItem, queries [][]float64, k int, filter services.VectorFilter) annBenchResult {
+	var res annBenchResult
+	if len(items) > 0 {
+		start := time.Now()
+		// Insert in batches as document uploads would
+		for i := 0; i < len(items); i += 50 {
+			end := i + 50
+			if end > len(items) {
+				end = len(items)
+			}
+			if err := store.Upsert(ctx, items[i:end]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return res
+			}
+		}
+		res.build = time.Since(start)
+	}
+
+	for _, q := range queries {
+		start := time.Now()
+		top, err := store.TopK(ctx, q, k, filter)
+		res.latencies = append(res.latencies, time.Since(start))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return res
+		}
+		ids := make([]string, len(top))
+		for i, it := range top {
+			ids[i] = it.ID
+		}
+		res.results = append(res.results, ids)
+	}
+	return res
+}
+
+func printBenchLine(name string, res annBenchResult, recall float64) {
+	sorted := append([]time.Duration(nil), res.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	var avg, p95 time.Duration
+	if len(sorted) > 0 {
+		avg = total / time.Duration(len(sorted))
+		p95 = sorted[len(sorted)*95/100]
+	}
+
+	build := "-"
+	if res.build > 0 {
+		build = res.build.Round(time.Millisecond).String()
+	}
+	fmt.Printf("  %-12s build: %-10s avg: %-12s p95: %-12s recall@k: %.3f\n", name, build, avg, p95, recall)
+}
+
+// recallAtK is the share of exact neighbours that the approximate search found
+func recallAtK(exact, approx [][]string) float64 {
+	var hits, total int
+	for i := range exact {
+		found := make(map[string]bool, len(approx[i]))
+		for _, id := range approx[i] {
+			found[id] = true
+		}
+		for _, id := range exact[i] {
+			total++
+			if found[id] {
+				hits++
+			}
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(hits) / float64(total)
+}
+
+// randomVector returns a unit vector near center, or uniform when center is nil
+func randomVector(rng *rand.Rand, dims int, center []float64, noise float64) []float64 {
+	v := make([]float64, dims)
+	var norm float64
+	for i := range v {
+		v[i] = rng.NormFloat64() * noise / math.Sqrt(float64(dims))
+		if center != nil {
+			v[i] += center[i]
+		}
+		norm += v[i] * v[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
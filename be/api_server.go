@@ -11,6 +11,8 @@ import (
 	"strings"
 	"time"
 
+	"pbkk-quizlit-backend/internal/extract"
+
 	"github.com/google/uuid"
 )
 
@@ -124,8 +126,8 @@ func (s *APIServer) handlePDFUpload(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// Validate file extension
-	if filepath.Ext(header.Filename) != ".pdf" {
-		s.sendError(w, "Invalid file type. Only PDF files are allowed", http.StatusBadRequest)
+	if !extract.Supported(header.Filename) {
+		s.sendError(w, "Invalid file type. Only "+extract.SupportedFormats+" files are allowed", http.StatusBadRequest)
 		return
 	}
 
@@ -215,8 +217,8 @@ func (s *APIServer) handlePDFInfo(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// Validate file extension
-	if filepath.Ext(header.Filename) != ".pdf" {
-		s.sendError(w, "Invalid file type. Only PDF files are allowed", http.StatusBadRequest)
+	if !extract.Supported(header.Filename) {
+		s.sendError(w, "Invalid file type. Only "+extract.SupportedFormats+" files are allowed", http.StatusBadRequest)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"time"
 
 	"pbkk-quizlit-backend/internal/config"
@@ -72,17 +73,72 @@ func NewServer(cfg *config.Config) *Server {
 func (s *Server) setupRoutes() {
 	// Initialize services
 	fileService := services.NewFileService()
-	aiService := services.NewAIServiceWithOptions(s.config.OpenAIKey, s.config.EnableRAG)
+	embedder, err := services.NewEmbeddingProvider(services.EmbeddingOptions{
+		Provider:  s.config.EmbeddingProvider,
+		BaseURL:   s.config.EmbeddingBaseURL,
+		Model:     s.config.EmbeddingModel,
+		APIKey:    s.config.EmbeddingAPIKey,
+		BatchSize: s.config.EmbeddingBatchSize,
+		CacheSize: s.config.EmbeddingCacheSize,
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("⚠️  Invalid embedding configuration, using local embeddings")
+	} else {
+		logrus.Infof("✅ RAG embeddings: %s", s.config.EmbeddingProvider)
+	}
+	vectorStore := s.newVectorStore()
+	aiService := services.NewAIServiceWithOptions(s.config.OpenAIKey, services.AIServiceOptions{
+		EnableRAG:              s.config.EnableRAG,
+		EnableStreaming:        s.config.EnableLLMStreaming,
+		QuestionBatchSize:      s.config.QuestionBatchSize,
+		MaxQuestionCount:       s.config.MaxQuestionCount,
+		QuestionLimitOverrides: s.config.QuestionLimitOverrides,
+		MaxConcurrentLLMCalls:  s.config.LLMMaxInFlight,
+		MaxQueuedLLMCalls:      s.config.LLMMaxQueue,
+		Senopati: services.SenopatiOptions{
+			MaxRetries:       s.config.SenopatiMaxRetries,
+			RetryBaseDelay:   time.Duration(s.config.SenopatiRetryBaseDelayMS) * time.Millisecond,
+			RetryMaxDelay:    time.Duration(s.config.SenopatiRetryMaxDelayMS) * time.Millisecond,
+			BreakerThreshold: s.config.SenopatiBreakerThreshold,
+			BreakerCooldown:  time.Duration(s.config.SenopatiBreakerCooldownSeconds) * time.Second,
+			CallBudget:       time.Duration(s.config.SenopatiCallBudgetSeconds) * time.Second,
+		},
+		Embedder:      embedder,
+		VectorStore:   vectorStore,
+		LexicalWeight: s.config.RAGLexicalWeight,
+		MMRLambda:     s.config.RAGMMRLambda,
+		ChatMinScore:  s.config.ChatMinScore,
+	})
 	quizService := services.NewQuizService()
+	chatService := services.NewChatService()
+	documentService := services.NewDocumentService(fileService)
+	// Deleted documents take their retrieval chunks and chat history with them
+	documentService.SetDeletionHandler(func(documentID string) {
+		aiService.EvictDocument(context.Background(), documentID)
+		if database.GetDB() == nil {
+			return
+		}
+		if err := chatService.DeleteDocument(context.Background(), documentID); err != nil {
+			logrus.WithError(err).Warnf("Failed to delete chat history of document %s", documentID)
+		}
+	})
 
 	// Initialize handlers
-	quizHandler := handlers.NewQuizHandler(quizService, aiService, fileService)
+	quizHandler := handlers.NewQuizHandler(quizService, aiService, fileService, documentService)
+	documentHandler := handlers.NewDocumentHandler(documentService, fileService, aiService, chatService)
 
 	// Health check
 	s.router.GET("/health", func(c *gin.Context) {
+		senopati := aiService.SenopatiHealth()
+		status := "healthy"
+		if senopati.State != services.CircuitClosed {
+			status = "degraded"
+		}
 		c.JSON(200, gin.H{
-			"status":  "healthy",
-			"message": "QuizLit API is running",
+			"status":    status,
+			"message":   "QuizLit API is running",
+			"llm_queue": aiService.SchedulerStats(),
+			"senopati":  senopati,
 		})
 	})
 
@@ -94,7 +150,9 @@ func (s *Server) setupRoutes() {
 		quizzes.Use(middleware.AuthMiddleware()) // Apply auth to all quiz routes
 		{
 			quizzes.POST("/upload", quizHandler.UploadFileAndGenerateQuiz)
+			quizzes.POST("/upload/inspect", quizHandler.InspectUpload)
 			quizzes.POST("/generate", quizHandler.GenerateQuizFromText)
+			quizzes.POST("/generate/stream", quizHandler.GenerateQuizFromTextStream)
 			quizzes.GET("/", quizHandler.GetAllQuizzes)
 			quizzes.GET("/:id", quizHandler.GetQuiz)
 			quizzes.PUT("/:id", quizHandler.UpdateQuiz)
@@ -104,11 +162,56 @@ func (s *Server) setupRoutes() {
 			quizzes.GET("/take/:id", quizHandler.GetQuizForTaking)
 			quizzes.POST("/submit", quizHandler.SubmitQuizAttempt)
 			quizzes.GET("/attempt/:id", quizHandler.GetQuizAttempt)
+			quizzes.POST("/attempt/:id/remedial", quizHandler.GenerateRemedialQuiz)
 			quizzes.GET("/attempts", quizHandler.ListUserAttempts)
 		}
+
+		// Document routes (protected)
+		documents := api.Group("/documents")
+		documents.Use(middleware.AuthMiddleware())
+		{
+			documents.POST("", documentHandler.UploadDocument)
+			documents.GET("", documentHandler.ListDocuments)
+			documents.GET("/:id", documentHandler.GetDocument)
+			documents.GET("/:id/file", documentHandler.DownloadDocument)
+			documents.POST("/:id/quizzes", quizHandler.GenerateQuizFromDocument)
+			documents.POST("/:id/summary", documentHandler.GenerateStudyNotes)
+			documents.DELETE("/:id", documentHandler.DeleteDocument)
+			documents.POST("/:id/chat", documentHandler.ChatWithDocument)
+			documents.GET("/:id/chat", documentHandler.GetChatHistory)
+		}
 	}
 }
 
 func (s *Server) Start() error {
 	return s.router.Run(":" + s.config.Port)
 }
+
+// newVectorStore picks where RAG embeddings are kept. "auto" uses Postgres
+// when the database is connected and migrated, and memory otherwise.
+func (s *Server) newVectorStore() services.VectorStore {
+	mode := s.config.VectorStore
+	if mode == "hnsw" {
+		logrus.Infof("✅ RAG vector store: hnsw (M=%d, efSearch=%d)", s.config.HNSWM, s.config.HNSWEfSearch)
+		return services.NewHNSWVectorStore(s.config.HNSWM, s.config.HNSWEfConstruction, s.config.HNSWEfSearch)
+	}
+	if mode == "memory" || (mode != "postgres" && database.GetDB() == nil) {
+		logrus.Info("✅ RAG vector store: memory")
+		return services.NewMemoryVectorStore()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store, err := services.NewPostgresVectorStore(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("⚠️  Postgres vector store unavailable, using memory")
+		return services.NewMemoryVectorStore()
+	}
+	if store.UsesPgvector() {
+		logrus.Info("✅ RAG vector store: postgres (pgvector)")
+	} else {
+		logrus.Info("✅ RAG vector store: postgres")
+	}
+	return store
+}
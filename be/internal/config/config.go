@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +18,41 @@ type Config struct {
 	SupabaseAnonKey   string
 	SupabaseJWTSecret string
 	EnableRAG         bool
+	// EnableLLMStreaming streams completions so questions are parsed as they arrive
+	EnableLLMStreaming bool
+	// Quiz size limits and batched generation
+	MaxQuestionCount       int
+	QuestionLimitOverrides map[string]int
+	QuestionBatchSize      int
+	// LLM call scheduling
+	LLMMaxInFlight int
+	LLMMaxQueue    int
+	// Senopati retries, circuit breaker and per-call time budget
+	SenopatiMaxRetries             int
+	SenopatiRetryBaseDelayMS       int
+	SenopatiRetryMaxDelayMS        int
+	SenopatiBreakerThreshold       int
+	SenopatiBreakerCooldownSeconds int
+	SenopatiCallBudgetSeconds      int
+	// RAG embeddings
+	EmbeddingProvider  string
+	EmbeddingBaseURL   string
+	EmbeddingModel     string
+	EmbeddingAPIKey    string
+	EmbeddingBatchSize int
+	EmbeddingCacheSize int
+	// VectorStore is "auto", "memory", "hnsw" or "postgres"
+	VectorStore string
+	// HNSW index parameters for VECTOR_STORE=hnsw
+	HNSWM              int
+	HNSWEfConstruction int
+	HNSWEfSearch       int
+	// RAGLexicalWeight is the share of BM25 in hybrid retrieval
+	RAGLexicalWeight float64
+	// RAGMMRLambda diversifies retrieved chunks; 0 disables MMR
+	RAGMMRLambda float64
+	// ChatMinScore is the retrieval confidence document chat needs to answer
+	ChatMinScore float64
 }
 
 func Load() *Config {
@@ -33,6 +70,36 @@ func Load() *Config {
 		SupabaseAnonKey:   getEnv("SUPABASE_ANON_KEY", ""),
 		SupabaseJWTSecret: getEnv("SUPABASE_JWT_SECRET", ""),
 		EnableRAG:         getEnv("ENABLE_RAG", "true") == "true",
+
+		EnableLLMStreaming: getEnv("ENABLE_LLM_STREAMING", "false") == "true",
+
+		MaxQuestionCount:       getEnvInt("MAX_QUESTION_COUNT", 100),
+		QuestionLimitOverrides: parseQuestionLimits(getEnv("QUESTION_LIMIT_OVERRIDES", "")),
+		QuestionBatchSize:      getEnvInt("QUESTION_BATCH_SIZE", 10),
+
+		LLMMaxInFlight: getEnvInt("LLM_MAX_IN_FLIGHT", 4),
+		LLMMaxQueue:    getEnvInt("LLM_MAX_QUEUE", 32),
+
+		SenopatiMaxRetries:             getEnvInt("SENOPATI_MAX_RETRIES", 3),
+		SenopatiRetryBaseDelayMS:       getEnvInt("SENOPATI_RETRY_BASE_DELAY_MS", 500),
+		SenopatiRetryMaxDelayMS:        getEnvInt("SENOPATI_RETRY_MAX_DELAY_MS", 10000),
+		SenopatiBreakerThreshold:       getEnvInt("SENOPATI_BREAKER_THRESHOLD", 5),
+		SenopatiBreakerCooldownSeconds: getEnvInt("SENOPATI_BREAKER_COOLDOWN_SECONDS", 30),
+		SenopatiCallBudgetSeconds:      getEnvInt("SENOPATI_CALL_BUDGET_SECONDS", 180),
+
+		EmbeddingProvider:  getEnv("EMBEDDING_PROVIDER", "local"),
+		EmbeddingBaseURL:   getEnv("EMBEDDING_BASE_URL", ""),
+		EmbeddingModel:     getEnv("EMBEDDING_MODEL", ""),
+		EmbeddingAPIKey:    getEnv("EMBEDDING_API_KEY", ""),
+		EmbeddingBatchSize: getEnvInt("EMBEDDING_BATCH_SIZE", 32),
+		EmbeddingCacheSize: getEnvInt("EMBEDDING_CACHE_SIZE", 10000),
+		VectorStore:        getEnv("VECTOR_STORE", "auto"),
+		HNSWM:              getEnvInt("HNSW_M", 16),
+		HNSWEfConstruction: getEnvInt("HNSW_EF_CONSTRUCTION", 200),
+		HNSWEfSearch:       getEnvInt("HNSW_EF_SEARCH", 64),
+		RAGLexicalWeight:   getEnvFloat("RAG_LEXICAL_WEIGHT", 0.5),
+		RAGMMRLambda:       getEnvFloat("RAG_MMR_LAMBDA", 0.7),
+		ChatMinScore:       getEnvFloat("CHAT_MIN_SCORE", 0.1),
 	}
 }
 
@@ -42,3 +109,52 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// parseQuestionLimits parses "user-id:limit" pairs separated by commas
+func parseQuestionLimits(value string) map[string]int {
+	limits := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		idx := strings.LastIndex(pair, ":")
+		if idx <= 0 {
+			log.Printf("Ignoring malformed question limit override: %q", pair)
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(pair[idx+1:]))
+		if err != nil || limit <= 0 {
+			log.Printf("Ignoring malformed question limit override: %q", pair)
+			continue
+		}
+		limits[strings.TrimSpace(pair[:idx])] = limit
+	}
+	return limits
+}
@@ -0,0 +1,106 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
+)
+
+// BlockKind is the kind of a block of text
+type BlockKind string
+
+const (
+	BlockHeading   BlockKind = "heading"
+	BlockParagraph BlockKind = "paragraph"
+	BlockListItem  BlockKind = "list_item"
+	BlockTable     BlockKind = "table"
+)
+
+// Block is a heading, paragraph, list item or Markdown table of a page
+type Block struct {
+	Kind BlockKind `json:"kind"`
+	Page int       `json:"page"`
+	// Level is the level of a heading, 1 for the top level
+	Level int    `json:"level,omitempty"`
+	Text  string `json:"text"`
+}
+
+// blockListItem matches the bullets and numbers extractors write list items with
+var blockListItem = regexp.MustCompile(`^\s*(?:[•▪◦·\-*]|\d+[.)]|[a-zA-Z][.)])\s`)
+
+// CodeFence returns the ``` or ~~~ marker when line opens or closes a
+// fenced code block, and "" otherwise
+func CodeFence(line string) string {
+	line = strings.TrimSpace(line)
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, fence) {
+			return fence
+		}
+	}
+	return ""
+}
+
+// pageBlocks splits the text of pages into blocks. Extractors write
+// headings as "#" lines and tables as "|" lines; other lines form
+// paragraphs up to a blank line or the next block. A fenced code block is
+// one paragraph whatever its lines look like.
+func pageBlocks(pages []models.DocumentPage) []Block {
+	var blocks []Block
+	for _, page := range pages {
+		var paragraph, table []string
+		fence := ""
+		flush := func() {
+			if len(paragraph) > 0 {
+				blocks = append(blocks, Block{Kind: BlockParagraph, Page: page.Number, Text: strings.Join(paragraph, " ")})
+				paragraph = nil
+			}
+			if len(table) > 0 {
+				blocks = append(blocks, Block{Kind: BlockTable, Page: page.Number, Text: strings.Join(table, "\n")})
+				table = nil
+			}
+		}
+
+		for _, line := range strings.Split(page.Text, "\n") {
+			line = strings.TrimSpace(line)
+			if marker := CodeFence(line); marker != "" && (fence == "" || marker == fence) {
+				flush()
+				if fence == "" {
+					fence = marker
+				} else {
+					fence = ""
+				}
+				continue
+			}
+			switch {
+			case fence != "":
+				if line != "" {
+					paragraph = append(paragraph, line)
+				}
+			case line == "":
+				flush()
+			case strings.HasPrefix(line, "|"):
+				if len(paragraph) > 0 {
+					flush()
+				}
+				table = append(table, line)
+			case strings.HasPrefix(line, "#"):
+				flush()
+				text := strings.TrimLeft(line, "#")
+				if title := strings.TrimSpace(text); title != "" {
+					blocks = append(blocks, Block{Kind: BlockHeading, Page: page.Number, Level: len(line) - len(text), Text: title})
+				}
+			case blockListItem.MatchString(line):
+				flush()
+				blocks = append(blocks, Block{Kind: BlockListItem, Page: page.Number, Text: line})
+			default:
+				if len(table) > 0 {
+					flush()
+				}
+				paragraph = append(paragraph, line)
+			}
+		}
+		flush()
+	}
+	return blocks
+}
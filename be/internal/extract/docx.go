@@ -0,0 +1,318 @@
+package extract
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DOCX extracts Word documents
+type DOCX struct{}
+
+// Extract validates a Word document held in memory and extracts its text
+// with headings as "#" lines, list items with their bullet or number, and
+// tables as Markdown tables
+func (DOCX) Extract(content []byte, filename string) (*Document, error) {
+	if int64(len(content)) > MaxFileSize {
+		return nil, fmt.Errorf("file too large (max allowed: %d bytes)", MaxFileSize)
+	}
+
+	zr, err := openZipPackage(content)
+	if err != nil {
+		return nil, fmt.Errorf("file does not appear to be a valid DOCX: %s", filename)
+	}
+	if err := validateOOXMLContent(zr, filename, "DOCX", "word/document.xml"); err != nil {
+		return nil, err
+	}
+
+	document, err := readZipXML(zr, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	styles, err := readZipXML(zr, "word/styles.xml")
+	if err != nil {
+		return nil, err
+	}
+	numbering, err := readZipXML(zr, "word/numbering.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &docxWriter{
+		styles:   parseDOCXStyles(styles),
+		lists:    parseDOCXNumbering(numbering),
+		counters: make(map[string][]int),
+	}
+	w.writeBlocks(document.child("body"))
+
+	text := w.String()
+	if text == "" {
+		return nil, fmt.Errorf("no text content found in DOCX")
+	}
+	// Word does not store page boundaries, so the document is one unnumbered page
+	return &Document{Text: text, Pages: singlePage(text), Metadata: ooxmlMetadata(zr)}, nil
+}
+
+// docxStyle is the part of a paragraph style that matters for extraction
+type docxStyle struct {
+	name    string
+	basedOn string
+	// outline is the outline level from 0, or -1 for body text
+	outline int
+	numID   string
+	ilvl    string
+}
+
+// maxDOCXListStart bounds the number a list level may start at
+const maxDOCXListStart = 9999
+
+// docxListLevel is the numbering format of one level of a list
+type docxListLevel struct {
+	format string
+	start  int
+}
+
+var docxHeadingStylePattern = regexp.MustCompile(`(?i)^heading\s*([1-9])$`)
+
+func parseDOCXStyles(root *xmlNode) map[string]docxStyle {
+	styles := make(map[string]docxStyle)
+	if root == nil {
+		return styles
+	}
+	for i := range root.Nodes {
+		node := &root.Nodes[i]
+		if node.XMLName.Local != "style" || node.attr("type") != "paragraph" {
+			continue
+		}
+		style := docxStyle{
+			name:    node.child("name").attrOrEmpty("val"),
+			basedOn: node.child("basedOn").attrOrEmpty("val"),
+			outline: -1,
+		}
+		pPr := node.child("pPr")
+		if lvl, err := strconv.Atoi(pPr.child("outlineLvl").attrOrEmpty("val")); err == nil && lvl < 9 {
+			style.outline = lvl
+		}
+		if numPr := pPr.child("numPr"); numPr != nil {
+			style.numID = numPr.child("numId").attrOrEmpty("val")
+			style.ilvl = numPr.child("ilvl").attrOrEmpty("val")
+		}
+		styles[node.attr("styleId")] = style
+	}
+	return styles
+}
+
+// parseDOCXNumbering maps each list (numId) to the formats of its levels
+func parseDOCXNumbering(root *xmlNode) map[string]map[string]docxListLevel {
+	lists := make(map[string]map[string]docxListLevel)
+	if root == nil {
+		return lists
+	}
+
+	abstract := make(map[string]map[string]docxListLevel)
+	for i := range root.Nodes {
+		node := &root.Nodes[i]
+		if node.XMLName.Local != "abstractNum" {
+			continue
+		}
+		levels := make(map[string]docxListLevel)
+		for j := range node.Nodes {
+			lvl := &node.Nodes[j]
+			if lvl.XMLName.Local != "lvl" {
+				continue
+			}
+			start, err := strconv.Atoi(lvl.child("start").attrOrEmpty("val"))
+			if err != nil {
+				start = 1
+			}
+			// A crafted start would otherwise make huge markers
+			start = min(max(start, 0), maxDOCXListStart)
+			levels[lvl.attr("ilvl")] = docxListLevel{format: lvl.child("numFmt").attrOrEmpty("val"), start: start}
+		}
+		abstract[node.attr("abstractNumId")] = levels
+	}
+
+	for i := range root.Nodes {
+		node := &root.Nodes[i]
+		if node.XMLName.Local == "num" {
+			lists[node.attr("numId")] = abstract[node.child("abstractNumId").attrOrEmpty("val")]
+		}
+	}
+	return lists
+}
+
+// docxWriter renders the body of a Word document as structured text
+type docxWriter struct {
+	styles map[string]docxStyle
+	lists  map[string]map[string]docxListLevel
+	// counters holds the current item number of every level of each list
+	counters map[string][]int
+
+	blockWriter
+}
+
+func (w *docxWriter) writeBlocks(parent *xmlNode) {
+	if parent == nil {
+		return
+	}
+	for i := range parent.Nodes {
+		node := &parent.Nodes[i]
+		switch node.XMLName.Local {
+		case "p":
+			w.writeParagraph(node)
+		case "tbl":
+			w.writeTable(node)
+		case "sdt":
+			// Skip generated tables of contents; they repeat the headings
+			gallery := node.child("sdtPr").child("docPartObj").child("docPartGallery").attrOrEmpty("val")
+			if gallery != "Table of Contents" {
+				w.writeBlocks(node.child("sdtContent"))
+			}
+		case "customXml", "ins":
+			w.writeBlocks(node)
+		}
+	}
+}
+
+func (w *docxWriter) writeParagraph(p *xmlNode) {
+	text := ooxmlText(p)
+	if text == "" {
+		return
+	}
+
+	pPr := p.child("pPr")
+	styleID := pPr.child("pStyle").attrOrEmpty("val")
+
+	level := -1
+	if lvl, err := strconv.Atoi(pPr.child("outlineLvl").attrOrEmpty("val")); err == nil && lvl < 9 {
+		level = lvl
+	} else {
+		level = w.styleOutline(styleID)
+	}
+	if level >= 0 {
+		w.write(strings.Repeat("#", min(level+1, 6))+" "+text, false)
+		return
+	}
+
+	numID, ilvl := "", ""
+	if numPr := pPr.child("numPr"); numPr != nil {
+		numID = numPr.child("numId").attrOrEmpty("val")
+		ilvl = numPr.child("ilvl").attrOrEmpty("val")
+	} else if style, ok := w.styles[styleID]; ok {
+		numID, ilvl = style.numID, style.ilvl
+	}
+	if numID != "" && numID != "0" {
+		w.write(w.listMarker(numID, ilvl)+text, true)
+		return
+	}
+
+	w.write(text, false)
+}
+
+// styleOutline returns the heading level of a paragraph style from 0, or -1
+// when paragraphs of the style are body text
+func (w *docxWriter) styleOutline(styleID string) int {
+	// Follow basedOn so custom styles derived from a heading count too
+	for depth := 0; styleID != "" && depth < 10; depth++ {
+		style, ok := w.styles[styleID]
+		if !ok {
+			// Documents without styles.xml still use the built-in style IDs
+			if m := docxHeadingStylePattern.FindStringSubmatch(styleID); m != nil {
+				lvl, _ := strconv.Atoi(m[1])
+				return lvl - 1
+			}
+			return -1
+		}
+		if style.outline >= 0 {
+			return style.outline
+		}
+		if m := docxHeadingStylePattern.FindStringSubmatch(style.name); m != nil {
+			lvl, _ := strconv.Atoi(m[1])
+			return lvl - 1
+		}
+		if strings.EqualFold(style.name, "title") {
+			return 0
+		}
+		styleID = style.basedOn
+	}
+	return -1
+}
+
+// listMarker returns the indented bullet or number of the next item of a
+// list level, restarting the numbering of deeper levels. Numbers end in ")"
+// so "1) Observe" is not mistaken for a numbered heading.
+func (w *docxWriter) listMarker(numID, ilvl string) string {
+	level, err := strconv.Atoi(ilvl)
+	if err != nil || level < 0 || level > 8 {
+		level = 0
+	}
+	counts := w.counters[numID]
+	if counts == nil {
+		counts = make([]int, 9)
+		w.counters[numID] = counts
+	}
+	counts[level]++
+	for i := level + 1; i < len(counts); i++ {
+		counts[i] = 0
+	}
+
+	indent := strings.Repeat("  ", level)
+	lvl, ok := w.lists[numID][strconv.Itoa(level)]
+	if !ok {
+		return indent + "- "
+	}
+	n := lvl.start + counts[level] - 1
+
+	switch lvl.format {
+	case "bullet":
+		return indent + "- "
+	case "none":
+		return indent
+	case "lowerLetter":
+		return indent + alphabetic(n, 'a') + ") "
+	case "upperLetter":
+		return indent + alphabetic(n, 'A') + ") "
+	case "lowerRoman":
+		return indent + strings.ToLower(roman(n)) + ") "
+	case "upperRoman":
+		return indent + roman(n) + ") "
+	default:
+		return indent + strconv.Itoa(n) + ") "
+	}
+}
+
+// alphabetic numbers items a, b, ..., z, aa, bb, ..., zz like Word does,
+// and in digits after that
+func alphabetic(n int, first rune) string {
+	if n < 1 {
+		n = 1
+	}
+	if n > 2*26 {
+		return strconv.Itoa(n)
+	}
+	return strings.Repeat(string(first+rune((n-1)%26)), (n-1)/26+1)
+}
+
+func roman(n int) string {
+	if n < 1 || n > 3999 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+// writeTable renders a table as a Markdown table whose first row is the header
+func (w *docxWriter) writeTable(tbl *xmlNode) {
+	if table := tableMarkdown(tbl); table != "" {
+		w.write(table, false)
+	}
+}
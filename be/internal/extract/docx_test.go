@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+)
+
+// numberingXML returns a numbering part with one single-level list
+func numberingXML(format, start string) string {
+	return fmt.Sprintf(`<w:numbering xmlns:w="w">
+<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="%s"/><w:numFmt w:val="%s"/></w:lvl></w:abstractNum>
+<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+</w:numbering>`, start, format)
+}
+
+func TestListMarker(t *testing.T) {
+	tests := []struct {
+		format, start string
+		want          []string
+	}{
+		{"decimal", "1", []string{"1) ", "2) "}},
+		{"decimal", "", []string{"1) ", "2) "}},
+		{"lowerLetter", "1", []string{"a) ", "b) "}},
+		{"upperLetter", "26", []string{"Z) ", "AA) "}},
+		{"lowerLetter", "52", []string{"zz) ", "53) "}},
+		{"lowerRoman", "3", []string{"iii) ", "iv) "}},
+		{"bullet", "1", []string{"- ", "- "}},
+		// Out of range starts are clamped instead of building huge markers
+		{"lowerLetter", "100000000000000", []string{"9999) ", "10000) "}},
+		{"lowerLetter", "1000000000", []string{"9999) ", "10000) "}},
+		{"upperRoman", "99999999", []string{"9999) ", "10000) "}},
+		{"decimal", "-5", []string{"0) ", "1) "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.start, func(t *testing.T) {
+			var root xmlNode
+			if err := xml.Unmarshal([]byte(numberingXML(tt.format, tt.start)), &root); err != nil {
+				t.Fatalf("parse numbering: %v", err)
+			}
+			w := &docxWriter{lists: parseDOCXNumbering(&root), counters: make(map[string][]int)}
+			for i, want := range tt.want {
+				if got := w.listMarker("1", "0"); got != want {
+					t.Errorf("item %d: listMarker() = %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+}
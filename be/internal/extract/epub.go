@@ -0,0 +1,306 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// epubFontObfuscation are the encryption algorithms EPUB uses to obfuscate
+// embedded fonts; anything else in encryption.xml means DRM
+var epubFontObfuscation = map[string]bool{
+	"http://www.idpf.org/2008/embedding": true,
+	"http://ns.adobe.com/pdf/enc#RC":     true,
+}
+
+// epubItem is an entry of the OPF manifest
+type epubItem struct {
+	href       string
+	mediaType  string
+	properties string
+}
+
+// EPUB extracts e-books
+type EPUB struct{}
+
+// Extract reads an e-book held in memory chapter by chapter, following
+// the reading order of its OPF spine. Every chapter becomes a page numbered
+// in reading order and titled from the book's table of contents.
+func (EPUB) Extract(content []byte, filename string) (*Document, error) {
+	if int64(len(content)) > MaxFileSize {
+		return nil, fmt.Errorf("file too large (max allowed: %d bytes)", MaxFileSize)
+	}
+
+	zr, err := openZipPackage(content)
+	if err != nil {
+		return nil, fmt.Errorf("file does not appear to be a valid EPUB: %s", filename)
+	}
+	if err := validateEPUBContent(zr, filename); err != nil {
+		return nil, err
+	}
+
+	opfPath, err := epubPackagePath(zr)
+	if err != nil {
+		return nil, err
+	}
+	opf, err := readZipXML(zr, opfPath)
+	if err != nil {
+		return nil, err
+	}
+	if opf == nil {
+		return nil, fmt.Errorf("EPUB package document %s is missing", opfPath)
+	}
+
+	manifest := make(map[string]epubItem)
+	for _, item := range opf.child("manifest").childrenNamed("item") {
+		manifest[item.attr("id")] = epubItem{
+			href:       epubResolve(opfPath, item.attr("href")),
+			mediaType:  item.attr("media-type"),
+			properties: item.attr("properties"),
+		}
+	}
+
+	spine := opf.child("spine")
+	titles, err := epubTOCTitles(zr, manifest, spine.attrOrEmpty("toc"))
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	var pages []models.DocumentPage
+	for _, ref := range spine.childrenNamed("itemref") {
+		// Non-linear items such as footnote pages are outside the reading order
+		if ref.attr("linear") == "no" {
+			continue
+		}
+		item, ok := manifest[ref.attr("idref")]
+		if !ok || (item.mediaType != "application/xhtml+xml" && item.mediaType != "text/html") {
+			continue
+		}
+
+		data, err := readZipPart(zr, item.href)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return nil, fmt.Errorf("EPUB chapter %s is missing", item.href)
+		}
+		source, err := decodeText(data, "text/html", item.href)
+		if err != nil {
+			return nil, err
+		}
+		chapterText, err := htmlDocumentText(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EPUB chapter %s: %w", item.href, err)
+		}
+		// Cover and image-only pages have no text
+		if chapterText == "" {
+			continue
+		}
+
+		number := len(pages) + 1
+		title := titles[item.href]
+		if title == "" {
+			title = firstHeading(chapterText)
+		}
+		if title == "" {
+			title = "Chapter " + strconv.Itoa(number)
+		}
+		// Start every chapter with a heading so chunks never span two chapters
+		if !strings.HasPrefix(chapterText, "#") {
+			chapterText = "# " + title + "\n\n" + chapterText
+		}
+
+		pages = append(pages, models.DocumentPage{Number: number, Title: title, Text: chapterText})
+		text.WriteString(chapterText)
+		text.WriteString("\n\n")
+	}
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no text content found in EPUB")
+	}
+	return &Document{Text: strings.TrimSpace(text.String()), Pages: pages, Metadata: epubMetadata(opf)}, nil
+}
+
+// epubMetadata reads the title, author and language of an e-book from the
+// Dublin Core metadata of its package document
+func epubMetadata(opf *xmlNode) Metadata {
+	metadata := opf.child("metadata")
+	return Metadata{
+		Title:    cleanMetadata(metadata.child("title").textOrEmpty()),
+		Author:   cleanMetadata(metadata.child("creator").textOrEmpty()),
+		Language: cleanMetadata(metadata.child("language").textOrEmpty()),
+	}
+}
+
+// validateEPUBContent checks that zr is an e-book that is not DRM protected
+func validateEPUBContent(zr *zipPackage, filename string) error {
+	container, err := readZipPart(zr, "META-INF/container.xml")
+	if err != nil {
+		return err
+	}
+	if container == nil {
+		return fmt.Errorf("file does not appear to be a valid EPUB: %s", filename)
+	}
+
+	encryption, err := readZipXML(zr, "META-INF/encryption.xml")
+	if err != nil || encryption == nil {
+		return err
+	}
+	for _, data := range encryption.childrenNamed("EncryptedData") {
+		if !epubFontObfuscation[data.child("EncryptionMethod").attrOrEmpty("Algorithm")] {
+			return fmt.Errorf("file is DRM protected and cannot be read: %s", filename)
+		}
+	}
+	return nil
+}
+
+// epubPackagePath returns the path of the OPF package document named in
+// META-INF/container.xml
+func epubPackagePath(zr *zipPackage) (string, error) {
+	container, err := readZipXML(zr, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+	for _, rootfile := range container.child("rootfiles").childrenNamed("rootfile") {
+		mediaType := rootfile.attr("media-type")
+		if fullPath := rootfile.attr("full-path"); fullPath != "" && (mediaType == "" || mediaType == "application/oebps-package+xml") {
+			return fullPath, nil
+		}
+	}
+	return "", fmt.Errorf("EPUB container does not name a package document")
+}
+
+// epubResolve resolves an href found in the part at base to a package path,
+// dropping any fragment
+func epubResolve(base, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(base), href)
+}
+
+// epubTOCTitles maps chapter files to their titles in the table of contents,
+// read from the EPUB 3 navigation document or else the EPUB 2 NCX
+func epubTOCTitles(zr *zipPackage, manifest map[string]epubItem, ncxID string) (map[string]string, error) {
+	titles := make(map[string]string)
+	add := func(href, title string) {
+		title = strings.Join(strings.Fields(title), " ")
+		// The first entry for a file is its chapter; later ones are sections
+		if _, ok := titles[href]; !ok && title != "" {
+			titles[href] = title
+		}
+	}
+
+	for _, item := range manifest {
+		if !containsWord(item.properties, "nav") {
+			continue
+		}
+		data, err := readZipPart(zr, item.href)
+		if err != nil || data == nil {
+			return titles, err
+		}
+		doc, err := html.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EPUB navigation: %w", err)
+		}
+		if nav := epubTOCNav(doc); nav != nil {
+			var collect func(n *html.Node)
+			collect = func(n *html.Node) {
+				if n.Type == html.ElementNode && n.DataAtom == atom.A {
+					if href, ok := htmlAttr(n, "href"); ok {
+						add(epubResolve(item.href, href), htmlText(n, false))
+					}
+				}
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					collect(c)
+				}
+			}
+			collect(nav)
+			return titles, nil
+		}
+	}
+
+	ncx, ok := manifest[ncxID]
+	if !ok {
+		return titles, nil
+	}
+	root, err := readZipXML(zr, ncx.href)
+	if err != nil || root == nil {
+		return titles, err
+	}
+	var collect func(n *xmlNode)
+	collect = func(n *xmlNode) {
+		for _, point := range n.childrenNamed("navPoint") {
+			src := point.child("content").attrOrEmpty("src")
+			if src != "" {
+				add(epubResolve(ncx.href, src), point.child("navLabel").child("text").textOrEmpty())
+			}
+			collect(point)
+		}
+	}
+	collect(root.child("navMap"))
+	return titles, nil
+}
+
+// epubTOCNav returns the table of contents nav of a navigation document,
+// falling back to its first nav
+func epubTOCNav(doc *html.Node) *html.Node {
+	var first, toc *html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if toc != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Nav {
+			if first == nil {
+				first = n
+			}
+			for _, a := range n.Attr {
+				if (a.Key == "epub:type" || a.Key == "type") && containsWord(a.Val, "toc") {
+					toc = n
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if toc != nil {
+		return toc
+	}
+	return first
+}
+
+// containsWord reports whether the space-separated list contains word
+func containsWord(list, word string) bool {
+	for _, w := range strings.Fields(list) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// firstHeading returns the text of the first "#" heading line of text
+func firstHeading(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "#") {
+			return strings.TrimSpace(strings.TrimLeft(line, "#"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+// Package extract reads the text and structure of documents: PDF, Word,
+// PowerPoint, EPUB, plain text, Markdown and HTML files. Every format has an
+// Extractor; Extract picks it from the content of the file.
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
+)
+
+const (
+	// MaxFileSize is the largest file that is extracted
+	MaxFileSize  = int64(20 << 20) // 20MB
+	sniffPDFSize = int64(1 << 20)  // 1MB
+	minPDFHeader = 5               // "%PDF-" is 5 bytes
+)
+
+// Content types of the binary formats
+const (
+	MIMEPDF  = "application/pdf"
+	MIMEDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MIMEPPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	MIMEEPUB = "application/epub+zip"
+)
+
+// supportedExtensions lists the file extensions accepted by Supported
+var supportedExtensions = []string{".pdf", ".docx", ".pptx", ".epub", ".txt", ".md", ".markdown", ".html", ".htm", ".xhtml"}
+
+// SupportedFormats names the accepted file extensions in error messages
+var SupportedFormats = strings.Join(supportedExtensions[:len(supportedExtensions)-1], ", ") +
+	" and " + supportedExtensions[len(supportedExtensions)-1]
+
+// Document is the text and structure extracted from a file
+type Document struct {
+	// ContentType is the type sniffed from the file's content
+	ContentType string
+	// Text is the whole text, with blank lines between pages
+	Text string
+	// Pages keeps the text page by page with its line breaks. Formats
+	// without pages give one page numbered 0.
+	Pages []models.DocumentPage
+	// Blocks are the headings, paragraphs, list items and tables of the
+	// pages in reading order
+	Blocks []Block
+	// Sections is the outline of a PDF as a section tree
+	Sections []models.DocumentSection
+	Metadata Metadata
+}
+
+// Metadata describes a document as its file does
+type Metadata struct {
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Language string `json:"language,omitempty"`
+	// PageCount is the number of pages of a PDF, including those without
+	// text; for slide decks and e-books it is the number of the last slide
+	// or chapter with text, and 0 when the format has no pages
+	PageCount int `json:"page_count"`
+}
+
+// Extractor reads the text and structure of one document format
+type Extractor interface {
+	Extract(content []byte, filename string) (*Document, error)
+}
+
+// Supported reports whether filename has the extension of a supported format
+func Supported(filename string) bool {
+	return slices.Contains(supportedExtensions, strings.ToLower(filepath.Ext(filename)))
+}
+
+// ForContent returns the extractor for a file, chosen by its sniffed
+// content type rather than its name
+func ForContent(content []byte, filename string) (Extractor, error) {
+	mime := ContentType(content)
+	switch mime {
+	case MIMEPDF:
+		return PDF{}, nil
+	case MIMEDOCX:
+		return DOCX{}, nil
+	case MIMEPPTX:
+		return PPTX{}, nil
+	case MIMEEPUB:
+		return EPUB{}, nil
+	}
+
+	// Text formats look alike to a sniffer, so the extension picks the
+	// markup once the content is known to be text
+	if !strings.HasPrefix(mime, "text/") {
+		return nil, fmt.Errorf("unsupported content type '%s' in %s (only %s allowed)", mime, filename, SupportedFormats)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return Markdown{}, nil
+	case ".html", ".htm", ".xhtml":
+		return HTML{}, nil
+	case ".txt":
+		return Text{}, nil
+	}
+	if strings.HasPrefix(mime, "text/html") {
+		return HTML{}, nil
+	}
+	return Text{}, nil
+}
+
+// Extract extracts a document held in memory with the extractor for its
+// content
+func Extract(content []byte, filename string) (*Document, error) {
+	extractor, err := ForContent(content, filename)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := extractor.Extract(content, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.ContentType = ContentType(content)
+	doc.Blocks = pageBlocks(doc.Pages)
+	if doc.Metadata.PageCount == 0 {
+		for _, page := range doc.Pages {
+			doc.Metadata.PageCount = max(doc.Metadata.PageCount, page.Number)
+		}
+	}
+	return doc, nil
+}
+
+// ExtractFile extracts the document at path
+func ExtractFile(path string) (*Document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.Size() > MaxFileSize {
+		return nil, fmt.Errorf("file too large (max allowed: %d bytes)", MaxFileSize)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	return Extract(content, filepath.Base(path))
+}
+
+// ContentType detects the MIME type of content, looking inside zip
+// archives to tell Office documents and e-books apart
+func ContentType(content []byte) string {
+	if bytes.HasPrefix(content, []byte("%PDF-")) {
+		return MIMEPDF
+	}
+	mime := http.DetectContentType(content[:min(len(content), 512)])
+	if mime != "application/zip" {
+		return mime
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return mime
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return MIMEDOCX
+		case "ppt/presentation.xml":
+			return MIMEPPTX
+		case "META-INF/container.xml":
+			return MIMEEPUB
+		}
+	}
+	return mime
+}
+
+// singlePage wraps the text of a format without pages as one page numbered 0
+func singlePage(text string) []models.DocumentPage {
+	return []models.DocumentPage{{Number: 0, Text: text}}
+}
+
+// cleanMetadata collapses the whitespace of a metadata value
+func cleanMetadata(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,312 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML extracts web pages
+type HTML struct{}
+
+// Extract reads an HTML page, keeping headings as "#" lines, lists and
+// tables, and dropping scripts, styles, forms and navigation boilerplate.
+// When the page marks its content with <main> or <article>, only that is read.
+func (HTML) Extract(content []byte, filename string) (*Document, error) {
+	source, err := decodeText(content, "text/html", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	text := htmlNodeText(doc)
+	if text == "" {
+		return nil, fmt.Errorf("no text content found in %s", filename)
+	}
+	return &Document{Text: text, Pages: singlePage(text), Metadata: htmlMetadata(doc)}, nil
+}
+
+// htmlDocumentText parses an HTML document and renders its content as
+// structured text
+func htmlDocumentText(source string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return htmlNodeText(doc), nil
+}
+
+// htmlNodeText renders the content of a parsed HTML document as structured
+// text
+func htmlNodeText(doc *html.Node) string {
+	root := findHTMLElement(doc, atom.Main)
+	if root == nil {
+		root = findHTMLElement(doc, atom.Article)
+	}
+	if root == nil {
+		root = findHTMLElement(doc, atom.Body)
+	}
+	if root == nil {
+		root = doc
+	}
+
+	w := &htmlWriter{}
+	w.walk(root)
+	w.flush()
+	return w.String()
+}
+
+// htmlMetadata reads the title, author and language of an HTML document
+// from its <title>, <meta name="author"> and lang attribute
+func htmlMetadata(doc *html.Node) Metadata {
+	var metadata Metadata
+	if title := findHTMLElement(doc, atom.Title); title != nil && title.FirstChild != nil {
+		metadata.Title = cleanMetadata(title.FirstChild.Data)
+	}
+	if root := findHTMLElement(doc, atom.Html); root != nil {
+		lang, _ := htmlAttr(root, "lang")
+		metadata.Language = cleanMetadata(lang)
+	}
+	var findAuthor func(n *html.Node)
+	findAuthor = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+			if name, _ := htmlAttr(n, "name"); strings.EqualFold(name, "author") {
+				content, _ := htmlAttr(n, "content")
+				metadata.Author = cleanMetadata(content)
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil && metadata.Author == ""; c = c.NextSibling {
+			findAuthor(c)
+		}
+	}
+	findAuthor(doc)
+	return metadata
+}
+
+// findHTMLElement returns the first element of the given type in document order
+func findHTMLElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findHTMLElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// htmlSkippedElements never hold readable content
+var htmlSkippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Iframe: true, atom.Object: true, atom.Svg: true,
+	atom.Canvas: true, atom.Form: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Nav: true, atom.Aside: true,
+}
+
+// htmlBoilerplateRoles mark navigation, banners and footers
+var htmlBoilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+}
+
+// htmlBlockElements start and end a paragraph
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Address: true, atom.Details: true,
+	atom.Summary: true, atom.Hr: true, atom.Header: true, atom.Footer: true, atom.Caption: true,
+}
+
+var htmlHeadingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+func htmlAttr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// skipHTMLElement reports whether n is markup or boilerplate rather than
+// content. Page headers and footers are dropped, but not the header of an
+// article or section, which usually holds its title.
+func skipHTMLElement(n *html.Node) bool {
+	if htmlSkippedElements[n.DataAtom] {
+		return true
+	}
+	if _, hidden := htmlAttr(n, "hidden"); hidden {
+		return true
+	}
+	if v, _ := htmlAttr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	if role, _ := htmlAttr(n, "role"); htmlBoilerplateRoles[role] {
+		return true
+	}
+	if n.DataAtom == atom.Header || n.DataAtom == atom.Footer {
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.DataAtom == atom.Article || p.DataAtom == atom.Section || p.DataAtom == atom.Main {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// htmlWriter renders an HTML tree as structured text
+type htmlWriter struct {
+	blockWriter
+	// inline collects the text of the current paragraph
+	inline strings.Builder
+}
+
+// flush ends the current paragraph
+func (w *htmlWriter) flush() {
+	if text := strings.Join(strings.Fields(w.inline.String()), " "); text != "" {
+		w.write(text, false)
+	}
+	w.inline.Reset()
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.inline.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if skipHTMLElement(n) {
+			return
+		}
+		if level, ok := htmlHeadingLevels[n.DataAtom]; ok {
+			w.flush()
+			if text := htmlText(n, false); text != "" {
+				w.write(strings.Repeat("#", level)+" "+text, false)
+			}
+			return
+		}
+		switch n.DataAtom {
+		case atom.Ul, atom.Ol:
+			w.flush()
+			w.writeList(n, 0)
+			return
+		case atom.Table:
+			w.flush()
+			if table := htmlTableMarkdown(n); table != "" {
+				w.write(table, false)
+			}
+			return
+		case atom.Br:
+			w.inline.WriteString(" ")
+			return
+		}
+		if htmlBlockElements[n.DataAtom] {
+			w.flush()
+			defer w.flush()
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+}
+
+// writeList writes the items of a ul or ol, numbering ordered items as
+// "1) " and indenting nested lists
+func (w *htmlWriter) writeList(list *html.Node, depth int) {
+	number := 1
+	if start, ok := htmlAttr(list, "start"); ok {
+		if n, err := strconv.Atoi(start); err == nil {
+			number = n
+		}
+	}
+	indent := strings.Repeat("  ", depth)
+
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li || skipHTMLElement(li) {
+			continue
+		}
+		marker := "- "
+		if list.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ") "
+			number++
+		}
+		if text := htmlText(li, true); text != "" {
+			w.write(indent+marker+text, true)
+		}
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) {
+				w.writeList(c, depth+1)
+			}
+		}
+	}
+}
+
+// htmlTableMarkdown renders an HTML table as a Markdown table
+func htmlTableMarkdown(table *html.Node) string {
+	var rows [][]string
+	columns := 0
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(c)
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						row = append(row, strings.ReplaceAll(htmlText(cell, false), "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+					columns = max(columns, len(row))
+				}
+			}
+		}
+	}
+	collect(table)
+	if len(rows) == 0 {
+		return ""
+	}
+	return markdownTable(rows, columns)
+}
+
+// htmlText returns the visible text under n with whitespace collapsed,
+// optionally leaving out nested lists
+func htmlText(n *html.Node, skipLists bool) string {
+	var b strings.Builder
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode {
+			if skipHTMLElement(n) || (skipLists && (n.DataAtom == atom.Ul || n.DataAtom == atom.Ol)) {
+				return
+			}
+			if n.DataAtom == atom.Br || htmlBlockElements[n.DataAtom] {
+				b.WriteString(" ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,355 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+const (
+	// maxOOXMLPartSize bounds the uncompressed size of each XML part read from
+	// an Office document, so a small zip cannot expand into gigabytes
+	maxOOXMLPartSize = int64(64 << 20) // 64MB
+	// maxZipPackageSize bounds the uncompressed size of all parts read from
+	// one document, so many parts cannot add up to gigabytes either
+	maxZipPackageSize = int64(256 << 20) // 256MB
+)
+
+// zipPackage is a zip-based document (DOCX, PPTX or EPUB) together with
+// what is left of its decompression budget
+type zipPackage struct {
+	*zip.Reader
+	remaining int64
+}
+
+// openZipPackage opens content as a zip package with a fresh budget of
+// maxZipPackageSize
+func openZipPackage(content []byte) (*zipPackage, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	return &zipPackage{Reader: zr, remaining: maxZipPackageSize}, nil
+}
+
+// xmlNode is a generic XML element, used to walk OOXML parts in document order
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []xmlNode  `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// attr returns the value of the attribute with the given local name
+func (n *xmlNode) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the given local name, or nil
+func (n *xmlNode) child(local string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == local {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns the child elements with the given local name
+func (n *xmlNode) childrenNamed(local string) []*xmlNode {
+	if n == nil {
+		return nil
+	}
+	var children []*xmlNode
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == local {
+			children = append(children, &n.Nodes[i])
+		}
+	}
+	return children
+}
+
+// textOrEmpty returns the character data of n, tolerating a missing element
+func (n *xmlNode) textOrEmpty() string {
+	if n == nil {
+		return ""
+	}
+	return n.Text
+}
+
+// attrOrEmpty is attr that tolerates a missing element
+func (n *xmlNode) attrOrEmpty(local string) string {
+	if n == nil {
+		return ""
+	}
+	return n.attr(local)
+}
+
+// readZipPart reads a file of a zip package, refusing to expand it past
+// maxOOXMLPartSize or the package's remaining budget. It returns nil without
+// an error when the file does not exist.
+func readZipPart(zr *zipPackage, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	limit := min(maxOOXMLPartSize, zr.remaining)
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if int64(len(data)) > maxOOXMLPartSize {
+		return nil, fmt.Errorf("%s is too large when uncompressed (max allowed: %d bytes)", name, maxOOXMLPartSize)
+	}
+	if int64(len(data)) > zr.remaining {
+		return nil, fmt.Errorf("document is too large when uncompressed (max allowed: %d bytes)", maxZipPackageSize)
+	}
+	zr.remaining -= int64(len(data))
+	return data, nil
+}
+
+// readZipXML parses an XML file of a zip package. It returns nil without an
+// error when the file does not exist.
+func readZipXML(zr *zipPackage, name string) (*xmlNode, error) {
+	data, err := readZipPart(zr, name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var node xmlNode
+	if err := xml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return &node, nil
+}
+
+// ooxmlMetadata reads the title, author and language of an Office document
+// from its core properties. A missing or broken part leaves them empty.
+func ooxmlMetadata(zr *zipPackage) Metadata {
+	core, err := readZipXML(zr, "docProps/core.xml")
+	if err != nil || core == nil {
+		return Metadata{}
+	}
+	return Metadata{
+		Title:    cleanMetadata(core.child("title").textOrEmpty()),
+		Author:   cleanMetadata(core.child("creator").textOrEmpty()),
+		Language: cleanMetadata(core.child("language").textOrEmpty()),
+	}
+}
+
+// relationshipsNamespace qualifies the r:id attributes that point at parts
+const relationshipsNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+// relationshipID returns the r:id attribute of n
+func (n *xmlNode) relationshipID() string {
+	for _, a := range n.Attrs {
+		if a.Name.Space == relationshipsNamespace && a.Name.Local == "id" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// ooxmlRelationship is an entry of a part's .rels file
+type ooxmlRelationship struct {
+	Type   string
+	Target string
+}
+
+// readRelationships returns the relationships of an OOXML part by ID, with
+// targets resolved to package paths
+func readRelationships(zr *zipPackage, part string) (map[string]ooxmlRelationship, error) {
+	dir, file := path.Split(part)
+	root, err := readZipXML(zr, dir+"_rels/"+file+".rels")
+	if err != nil || root == nil {
+		return nil, err
+	}
+
+	rels := make(map[string]ooxmlRelationship)
+	for i := range root.Nodes {
+		rel := &root.Nodes[i]
+		target := rel.attr("Target")
+		if !strings.EqualFold(rel.attr("TargetMode"), "External") {
+			if strings.HasPrefix(target, "/") {
+				target = strings.TrimPrefix(target, "/")
+			} else {
+				target = path.Join(dir, target)
+			}
+		}
+		rels[rel.attr("Id")] = ooxmlRelationship{Type: rel.attr("Type"), Target: target}
+	}
+	return rels, nil
+}
+
+// externalRelationshipTypes are relationships that make Office fetch remote
+// content when the document is opened
+var externalRelationshipTypes = []string{"/attachedTemplate", "/oleObject", "/subDocument", "/frame"}
+
+// validateOOXMLContent checks that zr is an Office document of the given
+// format containing mainPart, without macros, ActiveX controls or remotely
+// loaded content
+func validateOOXMLContent(zr *zipPackage, filename, format, mainPart string) error {
+	hasDocument := false
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case name == mainPart:
+			hasDocument = true
+		case strings.HasSuffix(name, "vbaproject.bin"):
+			return fmt.Errorf("file contains disallowed %s macros", format)
+		case strings.Contains(name, "/activex/"):
+			return fmt.Errorf("file contains disallowed %s ActiveX controls", format)
+		}
+	}
+	if !hasDocument {
+		return fmt.Errorf("file does not appear to be a valid %s: %s", format, filename)
+	}
+
+	for _, f := range zr.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".rels") {
+			continue
+		}
+		rels, err := readZipXML(zr, f.Name)
+		if err != nil {
+			return err
+		}
+		for _, rel := range rels.Nodes {
+			if !strings.EqualFold(rel.attr("TargetMode"), "External") {
+				continue
+			}
+			relType := rel.attr("Type")
+			for _, suffix := range externalRelationshipTypes {
+				if strings.HasSuffix(relType, suffix) {
+					return fmt.Errorf("file contains disallowed %s external content: %s", format, rel.attr("Target"))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// blockWriter joins extracted paragraphs, list items and tables into
+// structured text
+type blockWriter struct {
+	b        strings.Builder
+	lastList bool
+}
+
+func (w *blockWriter) String() string {
+	return strings.TrimSpace(w.b.String())
+}
+
+// write appends a block; consecutive list items stay on adjacent lines and
+// everything else is separated by a blank line
+func (w *blockWriter) write(block string, listItem bool) {
+	if w.b.Len() > 0 {
+		if listItem && w.lastList {
+			w.b.WriteString("\n")
+		} else {
+			w.b.WriteString("\n\n")
+		}
+	}
+	w.b.WriteString(block)
+	w.lastList = listItem
+}
+
+// tableMarkdown renders a Word or DrawingML table, which share the tr and tc
+// element names, as a Markdown table. It returns "" for an empty table.
+func tableMarkdown(tbl *xmlNode) string {
+	var rows [][]string
+	columns := 0
+	for i := range tbl.Nodes {
+		tr := &tbl.Nodes[i]
+		if tr.XMLName.Local != "tr" {
+			continue
+		}
+		var row []string
+		for j := range tr.Nodes {
+			tc := &tr.Nodes[j]
+			if tc.XMLName.Local != "tc" {
+				continue
+			}
+			row = append(row, strings.ReplaceAll(ooxmlText(tc), "|", `\|`))
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+			columns = max(columns, len(row))
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	return markdownTable(rows, columns)
+}
+
+// markdownTable formats rows as a Markdown table of the given width
+func markdownTable(rows [][]string, columns int) string {
+	var b strings.Builder
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("|")
+		for c := 0; c < columns; c++ {
+			cell := ""
+			if c < len(row) {
+				cell = row[c]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		if i == 0 {
+			b.WriteString("\n|" + strings.Repeat(" --- |", columns))
+		}
+	}
+	return b.String()
+}
+
+// ooxmlText collects the visible text under n, skipping deleted revisions,
+// field codes and the fallback copies of drawings
+func ooxmlText(n *xmlNode) string {
+	var b strings.Builder
+	collectOOXMLText(n, &b)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func collectOOXMLText(n *xmlNode, b *strings.Builder) {
+	switch n.XMLName.Local {
+	case "t":
+		b.WriteString(n.Text)
+		return
+	case "tab", "br", "cr":
+		b.WriteString(" ")
+		return
+	case "noBreakHyphen":
+		b.WriteString("-")
+		return
+	case "pPr", "rPr", "tcPr", "trPr", "tblPr", "tblGrid", "del", "delText", "instrText", "Fallback":
+		return
+	}
+	for i := range n.Nodes {
+		collectOOXMLText(&n.Nodes[i], b)
+	}
+	// Paragraphs inside table cells and text boxes are separate words
+	if n.XMLName.Local == "p" {
+		b.WriteString(" ")
+	}
+}
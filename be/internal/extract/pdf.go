@@ -0,0 +1,267 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDF extracts PDF documents page by page, together with their outline
+type PDF struct{}
+
+// Extract validates a PDF held in memory and extracts its text page by
+// page, reading tables from the layout of the page
+func (PDF) Extract(content []byte, filename string) (*Document, error) {
+	if int64(len(content)) > MaxFileSize {
+		return nil, fmt.Errorf("file too large (max allowed: %d bytes)", MaxFileSize)
+	}
+
+	if err := validatePDFContent(content, filename); err != nil {
+		return nil, err
+	}
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PDF reader: %w", err)
+	}
+
+	var text strings.Builder
+	var pages []models.DocumentPage
+	numPages := reader.NumPage()
+
+	for i := 1; i <= numPages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// Read the page from its glyph positions so tables keep their rows
+		// and columns, falling back to the plain text
+		pageText, ok := pdfLayoutText(page)
+		if !ok {
+			var err error
+			pageText, err = page.GetPlainText(nil)
+			if err != nil {
+				continue
+			}
+		}
+
+		// Clean and normalize the text line by line, so table rows stay apart
+		lines := pdfPageLines(pageText)
+		if lines == "" {
+			continue
+		}
+		text.WriteString(lines)
+		text.WriteString("\n\n") // Add paragraph breaks between pages
+		pages = append(pages, models.DocumentPage{Number: i, Text: lines})
+	}
+
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no text content found in PDF")
+	}
+	sections := ReadPDFOutline(reader)
+	// Pages without text, such as scans or trailing figures, still count
+	metadata := pdfMetadata(reader)
+	metadata.PageCount = numPages
+
+	return &Document{
+		Text:     strings.TrimSpace(text.String()),
+		Pages:    markOutlineHeadings(pages, sections),
+		Sections: sections,
+		Metadata: metadata,
+	}, nil
+}
+
+// pdfMetadata reads the title, author and language of a PDF from its
+// document information dictionary and catalog
+func pdfMetadata(reader *pdf.Reader) (metadata Metadata) {
+	// The reader panics on malformed objects; they just leave fields empty
+	defer func() {
+		recover()
+	}()
+
+	info := reader.Trailer().Key("Info")
+	metadata.Title = cleanMetadata(info.Key("Title").Text())
+	metadata.Author = cleanMetadata(info.Key("Author").Text())
+	metadata.Language = cleanMetadata(reader.Trailer().Key("Root").Key("Lang").Text())
+	return metadata
+}
+
+// pageLines cleans a page's text line by line, keeping the line breaks that
+// heading and paragraph detection rely on
+func pdfPageLines(pageText string) string {
+	var lines []string
+	for _, line := range strings.Split(pageText, "\n") {
+		if cleaned := normalizePDFText(cleanPDFText(line)); cleaned != "" {
+			lines = append(lines, cleaned)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cleanPDFText cleans up PDF text extraction artifacts
+func cleanPDFText(text string) string {
+	// First, remove common PDF artifacts
+	text = strings.ReplaceAll(text, "□", " ")
+	text = strings.ReplaceAll(text, "�", "")
+	text = strings.ReplaceAll(text, "\u00a0", " ") // non-breaking space
+
+	// Replace multiple spaces/newlines with single space
+	text = strings.Join(strings.Fields(text), " ")
+
+	// AGGRESSIVE word boundary detection
+	var result strings.Builder
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+		current := runes[i]
+		result.WriteRune(current)
+
+		if i < len(runes)-1 {
+			next := runes[i+1]
+
+			// Skip if already has space
+			if current == ' ' || next == ' ' {
+				continue
+			}
+
+			// Add space between lowercase and uppercase (camelCase)
+			// Example: "matriksselisih" → "matriks selisih"
+			if (current >= 'a' && current <= 'z') && (next >= 'A' && next <= 'Z') {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space between uppercase and lowercase (if previous was lowercase)
+			// Example: "SSdan" → "SS dan"
+			if i > 0 && (current >= 'A' && current <= 'Z') && (next >= 'a' && next <= 'z') {
+				prev := runes[i-1]
+				if prev >= 'a' && prev <= 'z' {
+					result.WriteRune(' ')
+					continue
+				}
+			}
+
+			// Add space between letter and number
+			if ((current >= 'a' && current <= 'z') || (current >= 'A' && current <= 'Z')) &&
+				(next >= '0' && next <= '9') {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space between number and letter
+			if (current >= '0' && current <= '9') &&
+				((next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z')) {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space after closing bracket if followed by letter
+			if (current == ')' || current == ']' || current == '}') &&
+				((next >= 'A' && next <= 'Z') || (next >= 'a' && next <= 'z')) {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space before opening bracket if preceded by letter
+			if ((current >= 'a' && current <= 'z') || (current >= 'A' && current <= 'Z')) &&
+				(next == '(' || next == '[' || next == '{') {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space after period if followed by uppercase (sentence boundary)
+			if current == '.' && next >= 'A' && next <= 'Z' {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space after comma if not already present
+			if current == ',' && ((next >= 'A' && next <= 'Z') || (next >= 'a' && next <= 'z')) {
+				result.WriteRune(' ')
+				continue
+			}
+
+			// Add space after colon/semicolon if followed by letter
+			if (current == ':' || current == ';') &&
+				((next >= 'A' && next <= 'Z') || (next >= 'a' && next <= 'z')) {
+				result.WriteRune(' ')
+				continue
+			}
+		}
+	}
+
+	return result.String()
+}
+
+// normalizePDFText performs final normalization
+func normalizePDFText(text string) string {
+	// Remove excessive whitespace
+	text = strings.Join(strings.Fields(text), " ")
+
+	// Fix common ligatures and special characters
+	replacements := map[string]string{
+		"ﬁ": "fi",
+		"ﬂ": "fl",
+		"ﬀ": "ff",
+		"ﬃ": "ffi",
+		"ﬄ": "ffl",
+		"□": " ", // Replace box character with space
+		"�": "",  // Remove replacement character
+	}
+
+	for old, new := range replacements {
+		text = strings.ReplaceAll(text, old, new)
+	}
+
+	// Ensure proper sentence spacing
+	text = strings.ReplaceAll(text, ". ", ". ")
+	text = strings.ReplaceAll(text, "? ", "? ")
+	text = strings.ReplaceAll(text, "! ", "! ")
+
+	// Remove multiple consecutive spaces again
+	text = strings.Join(strings.Fields(text), " ")
+
+	return strings.TrimSpace(text)
+}
+
+func validatePDFContent(content []byte, filename string) error {
+	if len(content) < minPDFHeader || !bytes.HasPrefix(content, []byte("%PDF-")) {
+		return fmt.Errorf("file does not appear to be a valid PDF: %s", filename)
+	}
+
+	// Verify content type
+	mime := http.DetectContentType(content[:min(len(content), 512)])
+	if mime != MIMEPDF && mime != "application/octet-stream" {
+		return fmt.Errorf("invalid content type '%s', expected application/pdf", mime)
+	}
+
+	// Scan for common active/suspicious markers
+	end := int64(len(content))
+	if end > sniffPDFSize {
+		end = sniffPDFSize
+	}
+	lower := bytes.ToLower(content[:end])
+	markers := [][]byte{
+		[]byte("/js"),
+		[]byte("/javascript"),
+		[]byte("/launch"),
+		[]byte("/embeddedfile"),
+		[]byte("/embeddedfiles"),
+		[]byte("/richmedia"),
+		[]byte("/openaction"),
+		[]byte("/uri"),
+	}
+	for _, marker := range markers {
+		if bytes.Contains(lower, marker) {
+			return fmt.Errorf("file contains disallowed PDF active content marker: %s", marker)
+		}
+	}
+
+	return nil
+}
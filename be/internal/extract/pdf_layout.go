@@ -0,0 +1,358 @@
+package extract
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// listMarkerPattern matches a bullet or list number on its own
+var listMarkerPattern = regexp.MustCompile(`^(?:[•▪◦·\-*]|\d+[.)]|[a-zA-Z][.)]|[ivxIVX]+[.)])$`)
+
+const (
+	// cellGap is the horizontal gap, in font sizes, that separates the cells
+	// of a table row or the columns of a page rather than two words
+	cellGap = 1.5
+	// wordGap is the smallest gap, in font sizes, read as a space between words
+	wordGap = 0.15
+	// rowGap is the largest vertical distance, in font sizes, between the
+	// baselines of two rows of the same table
+	rowGap = 3.0
+	// columnWords is the number of words per cell from which two aligned
+	// columns are read as columns of running text rather than a table
+	columnWords = 5
+)
+
+// layoutSegment is a run of text on a line that is separated from the rest
+// of the line by a wide gap, such as a table cell
+type layoutSegment struct {
+	X0, X1 float64
+	Text   string
+}
+
+// layoutLine is a line of text on a page, split into its segments
+type layoutLine struct {
+	Y        float64
+	Size     float64
+	Segments []layoutSegment
+}
+
+// pdfLayoutText rebuilds the text of a page from the positions of its
+// glyphs, rendering tabular regions as Markdown tables. Lines are kept in
+// the order the page draws them, so text laid out in columns is read one
+// column after the other. It reports false when the page has no positioned
+// text, in which case the caller should fall back to the plain text.
+func pdfLayoutText(page pdf.Page) (text string, ok bool) {
+	// The reader panics on content streams it cannot interpret
+	defer func() {
+		if recover() != nil {
+			text, ok = "", false
+		}
+	}()
+
+	lines := layoutLines(page.Content().Text, pdfLineBreakGlyphs(page))
+	if len(lines) == 0 {
+		return "", false
+	}
+	return renderLayout(lines), true
+}
+
+// pdfLineBreakGlyphs maps the fonts of a page to the text their encoding
+// gives a line feed. The reader emits a line feed glyph after every TJ
+// operator and decodes it with the current font, which turns it into a
+// letter such as "Ω" in TeX fonts.
+func pdfLineBreakGlyphs(page pdf.Page) map[string]string {
+	glyphs := make(map[string]string)
+	for _, name := range page.Fonts() {
+		font := page.Font(name)
+		baseFont := font.BaseFont()
+		if i := strings.Index(baseFont, "+"); i >= 0 {
+			baseFont = baseFont[i+1:]
+		}
+		if decoded := font.Encoder().Decode("\n"); decoded != "\n" && decoded != "" {
+			glyphs[baseFont] = decoded
+		}
+	}
+	return glyphs
+}
+
+// layoutLines groups glyphs into lines in drawing order, leaving out the
+// reader's line feed glyphs. A glyph starts a new line when its baseline
+// moves by more than half the font size, so lines at the same height in
+// different columns stay apart unless the page draws them one after the
+// other.
+func layoutLines(glyphs []pdf.Text, lineBreaks map[string]string) []layoutLine {
+	var lines []layoutLine
+	var current []pdf.Text
+	var lineY, lineSize float64
+
+	flush := func() {
+		if line, ok := newLayoutLine(current, lineY, lineSize); ok {
+			lines = append(lines, line)
+		}
+		current = nil
+	}
+
+	var prev pdf.Text
+	var prevAdvanced bool
+	for _, glyph := range glyphs {
+		if glyph.S == "\n" || (lineBreaks[glyph.Font] != "" && glyph.S == lineBreaks[glyph.Font]) {
+			continue
+		}
+		glyph.FontSize = math.Abs(glyph.FontSize)
+		if glyph.FontSize == 0 {
+			glyph.FontSize = 10
+		}
+		// Fonts without widths leave every glyph of a string at its start;
+		// lay them out with an estimated width instead
+		original := glyph
+		if glyph.W <= 0 {
+			glyph.W = estimatedWidth(glyph)
+			if !prevAdvanced && glyph.X == prev.X && glyph.Y == prev.Y && len(current) > 0 {
+				last := current[len(current)-1]
+				glyph.X = last.X + last.W
+			}
+		}
+		prev, prevAdvanced = original, original.W > 0
+
+		if len(current) > 0 && math.Abs(glyph.Y-lineY) > lineSize/2 {
+			flush()
+		}
+		if len(current) == 0 {
+			lineY, lineSize = glyph.Y, glyph.FontSize
+		}
+		lineSize = max(lineSize, glyph.FontSize)
+		current = append(current, glyph)
+	}
+	flush()
+	return lines
+}
+
+// estimatedWidth guesses the width of a glyph whose font gives none
+func estimatedWidth(glyph pdf.Text) float64 {
+	if strings.TrimSpace(glyph.S) == "" {
+		return glyph.FontSize * 0.25
+	}
+	return glyph.FontSize * 0.5 * float64(len([]rune(glyph.S)))
+}
+
+// newLayoutLine orders the glyphs of a line from left to right and splits
+// them into segments at wide gaps. It reports false for a blank line.
+func newLayoutLine(glyphs []pdf.Text, y, size float64) (layoutLine, bool) {
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].X < glyphs[j].X })
+
+	line := layoutLine{Y: y, Size: size}
+	var b strings.Builder
+	var segment layoutSegment
+	end := math.Inf(-1)
+	space := false
+
+	for _, glyph := range glyphs {
+		if strings.TrimSpace(glyph.S) == "" {
+			space = true
+			continue
+		}
+		if b.Len() > 0 {
+			gap := glyph.X - end
+			if gap > cellGap*size {
+				segment.Text = b.String()
+				line.Segments = append(line.Segments, segment)
+				b.Reset()
+			} else if space || gap > wordGap*size {
+				b.WriteString(" ")
+			}
+		}
+		if b.Len() == 0 {
+			segment = layoutSegment{X0: glyph.X}
+		}
+		b.WriteString(glyph.S)
+		end = max(end, glyph.X+glyph.W)
+		segment.X1 = end
+		space = false
+	}
+	if b.Len() == 0 {
+		return line, false
+	}
+	segment.Text = b.String()
+	line.Segments = append(line.Segments, segment)
+	return line, true
+}
+
+// renderLayout writes lines out as text. Consecutive lines split into
+// aligned segments become a Markdown table, or are read column by column
+// when the segments hold running text.
+func renderLayout(lines []layoutLine) string {
+	var out []string
+	for i := 0; i < len(lines); {
+		if len(lines[i].Segments) < 2 {
+			out = append(out, lines[i].text())
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(lines) && continuesRun(lines[i:j], lines[j]) {
+			j++
+		}
+		out = append(out, renderRun(lines[i:j])...)
+		i = j
+	}
+	return strings.Join(out, "\n")
+}
+
+// continuesRun reports whether line belongs to the same table or column
+// layout as run: it follows the run's last line closely and is either split
+// into segments itself, or fits in one of the run's columns or to the right
+// of the first, as the wrapped text of a cell does
+func continuesRun(run []layoutLine, line layoutLine) bool {
+	last := run[len(run)-1]
+	if drop := last.Y - line.Y; drop <= 0 || drop > rowGap*max(last.Size, line.Size) {
+		return false
+	}
+	if len(line.Segments) >= 2 {
+		return true
+	}
+
+	columns := layoutColumns(run)
+	if len(columns) < 2 {
+		return false
+	}
+	segment := line.Segments[0]
+	if segment.X0 > columns[0].X1 {
+		return true
+	}
+	for _, column := range columns {
+		if segment.X0 >= column.X0-line.Size && segment.X1 <= column.X1+line.Size {
+			return true
+		}
+	}
+	return false
+}
+
+// renderRun renders a run of lines split into segments
+func renderRun(run []layoutLine) []string {
+	columns := layoutColumns(run)
+	if len(run) < 2 || len(columns) < 2 {
+		return plainLines(run)
+	}
+
+	// A table needs at least two rows with several cells
+	split := 0
+	for _, line := range run {
+		if len(line.Segments) >= 2 {
+			split++
+		}
+	}
+	if split < 2 {
+		return plainLines(run)
+	}
+
+	// cells[i][c] is the text of line i in column c
+	cells := make([][]string, len(run))
+	words, filled := 0, 0
+	listMarkers := true
+	for i, line := range run {
+		cells[i] = make([]string, len(columns))
+		for _, segment := range line.Segments {
+			c := columnOf(columns, segment)
+			cells[i][c] = strings.TrimSpace(cells[i][c] + " " + segment.Text)
+		}
+		for _, cell := range cells[i] {
+			if cell != "" {
+				words += len(strings.Fields(cell))
+				filled++
+			}
+		}
+		if cells[i][0] != "" && !listMarkerPattern.MatchString(cells[i][0]) {
+			listMarkers = false
+		}
+	}
+
+	// List items set apart from their bullets or numbers are not a table
+	if listMarkers {
+		return plainLines(run)
+	}
+
+	// Two columns of running text are read one after the other
+	if len(columns) == 2 && words >= columnWords*filled {
+		var out []string
+		for c := range columns {
+			for i := range run {
+				if cells[i][c] != "" {
+					out = append(out, cells[i][c])
+				}
+			}
+		}
+		return out
+	}
+
+	// A row without a first cell holds the wrapped text of the row above
+	var rows [][]string
+	for i := range cells {
+		if len(rows) > 0 && cells[i][0] == "" {
+			above := rows[len(rows)-1]
+			for c, cell := range cells[i] {
+				above[c] = strings.TrimSpace(above[c] + " " + cell)
+			}
+			continue
+		}
+		rows = append(rows, cells[i])
+	}
+	for _, row := range rows {
+		for c := range row {
+			row[c] = strings.ReplaceAll(row[c], "|", `\|`)
+		}
+	}
+	return strings.Split(markdownTable(rows, len(columns)), "\n")
+}
+
+// plainLines renders lines as text, ignoring their layout
+func plainLines(lines []layoutLine) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = line.text()
+	}
+	return out
+}
+
+// layoutColumns merges the horizontal extents of the segments of run into
+// columns, ordered from left to right
+func layoutColumns(run []layoutLine) []layoutSegment {
+	var extents []layoutSegment
+	for _, line := range run {
+		extents = append(extents, line.Segments...)
+	}
+	sort.Slice(extents, func(i, j int) bool { return extents[i].X0 < extents[j].X0 })
+
+	var columns []layoutSegment
+	for _, extent := range extents {
+		if n := len(columns); n > 0 && extent.X0 <= columns[n-1].X1 {
+			columns[n-1].X1 = max(columns[n-1].X1, extent.X1)
+			continue
+		}
+		columns = append(columns, layoutSegment{X0: extent.X0, X1: extent.X1})
+	}
+	return columns
+}
+
+// columnOf returns the index of the column holding segment
+func columnOf(columns []layoutSegment, segment layoutSegment) int {
+	for c := len(columns) - 1; c > 0; c-- {
+		if segment.X0 >= columns[c].X0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// text joins the segments of a line with spaces
+func (l layoutLine) text() string {
+	texts := make([]string, len(l.Segments))
+	for i, segment := range l.Segments {
+		texts[i] = segment.Text
+	}
+	return strings.Join(texts, " ")
+}
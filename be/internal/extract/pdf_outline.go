@@ -0,0 +1,226 @@
+package extract
+
+import (
+	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxOutlineEntries bounds the outline walk, so a malformed outline that
+// links back to itself cannot loop forever
+const maxOutlineEntries = 10000
+
+// ReadPDFOutline reads the outline of a PDF into a section tree. Sections
+// are numbered in outline order, so a number picks the same section in the
+// tree and in FlattenSections.
+func ReadPDFOutline(reader *pdf.Reader) []models.DocumentSection {
+	return sectionTree(pdfOutline(reader))
+}
+
+// FlattenSections lists the sections of a tree in outline order, without
+// their children
+func FlattenSections(tree []models.DocumentSection) []models.DocumentSection {
+	var flat []models.DocumentSection
+	for _, section := range tree {
+		children := section.Children
+		section.Children = nil
+		flat = append(flat, section)
+		flat = append(flat, FlattenSections(children)...)
+	}
+	return flat
+}
+
+// sectionTree nests a flat outline by level, each section under the closest
+// preceding section of a higher level
+func sectionTree(flat []models.DocumentSection) []models.DocumentSection {
+	var build func(level int) []models.DocumentSection
+	i := 0
+	build = func(level int) []models.DocumentSection {
+		var sections []models.DocumentSection
+		for i < len(flat) && flat[i].Level >= level {
+			section := flat[i]
+			i++
+			section.Children = build(section.Level + 1)
+			sections = append(sections, section)
+		}
+		return sections
+	}
+	return build(1)
+}
+
+// pdfOutline reads the outline of a PDF as a flat list. A section runs from
+// its page up to the page before the next section at the same or a higher
+// level, or to the end of the document. Entries that do not point at a page
+// are left out.
+func pdfOutline(reader *pdf.Reader) (sections []models.DocumentSection) {
+	// The reader panics on malformed objects; a broken outline is no outline
+	defer func() {
+		if recover() != nil {
+			sections = nil
+		}
+	}()
+
+	root := reader.Trailer().Key("Root")
+	numPages := reader.NumPage()
+	pageNumbers := pdfPageNumbers(reader, numPages)
+
+	var walk func(item pdf.Value, level int)
+	walk = func(item pdf.Value, level int) {
+		for ; !item.IsNull() && len(sections) < maxOutlineEntries; item = item.Key("Next") {
+			title := strings.Join(strings.Fields(item.Key("Title").Text()), " ")
+			if page := pdfDestinationPage(root, item, pageNumbers, numPages); page > 0 && title != "" {
+				sections = append(sections, models.DocumentSection{
+					Number:    len(sections) + 1,
+					Title:     title,
+					Level:     level,
+					StartPage: page,
+				})
+			}
+			walk(item.Key("First"), level+1)
+		}
+	}
+	walk(root.Key("Outlines").Key("First"), 1)
+
+	for i := range sections {
+		sections[i].EndPage = numPages
+		for _, next := range sections[i+1:] {
+			if next.Level <= sections[i].Level {
+				sections[i].EndPage = max(sections[i].StartPage, next.StartPage-1)
+				break
+			}
+		}
+	}
+	return sections
+}
+
+// pdfPageNumbers maps page dictionaries to their page numbers. Pages are
+// matched by their printed dictionary since the reader does not expose
+// object numbers; a page's dictionary names its own content streams, so
+// it differs from every other page's.
+func pdfPageNumbers(reader *pdf.Reader, numPages int) map[string]int {
+	numbers := make(map[string]int, numPages)
+	for i := 1; i <= numPages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		if _, ok := numbers[page.V.String()]; !ok {
+			numbers[page.V.String()] = i
+		}
+	}
+	return numbers
+}
+
+// pdfDestinationPage returns the page an outline item points at, or 0. The
+// destination is either the item's /Dest or the /D of a GoTo action, and
+// may be given by name.
+func pdfDestinationPage(root, item pdf.Value, pageNumbers map[string]int, numPages int) int {
+	dest := item.Key("Dest")
+	if dest.IsNull() {
+		action := item.Key("A")
+		if action.Key("S").Name() != "GoTo" {
+			return 0
+		}
+		dest = action.Key("D")
+	}
+	if dest.Kind() == pdf.Name || dest.Kind() == pdf.String {
+		dest = pdfNamedDestination(root, dest)
+	}
+	// A named destination may be a dictionary holding the array as /D
+	if dest.Kind() == pdf.Dict {
+		dest = dest.Key("D")
+	}
+	if dest.Kind() != pdf.Array || dest.Len() == 0 {
+		return 0
+	}
+
+	page := dest.Index(0)
+	switch page.Kind() {
+	case pdf.Dict:
+		return pageNumbers[page.String()]
+	case pdf.Integer:
+		// Some writers give the 0-based page index instead of a reference
+		if n := int(page.Int64()) + 1; n >= 1 && n <= numPages {
+			return n
+		}
+	}
+	return 0
+}
+
+// pdfNamedDestination looks up a named destination in the catalog's /Dests
+// dictionary (PDF 1.1) or its /Names /Dests name tree
+func pdfNamedDestination(root, name pdf.Value) pdf.Value {
+	key := name.RawString()
+	if name.Kind() == pdf.Name {
+		key = name.Name()
+		if dest := root.Key("Dests").Key(key); !dest.IsNull() {
+			return dest
+		}
+	}
+	return pdfNameTreeLookup(root.Key("Names").Key("Dests"), key, 0)
+}
+
+// pdfNameTreeLookup finds key in a name tree, descending into its kids
+func pdfNameTreeLookup(node pdf.Value, key string, depth int) pdf.Value {
+	if node.IsNull() || depth > 32 {
+		return pdf.Value{}
+	}
+	names := node.Key("Names")
+	for i := 0; i+1 < names.Len(); i += 2 {
+		if names.Index(i).RawString() == key {
+			return names.Index(i + 1)
+		}
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		kid := kids.Index(i)
+		// Skip kids whose key range cannot hold the name
+		if limits := kid.Key("Limits"); limits.Len() == 2 &&
+			(key < limits.Index(0).RawString() || key > limits.Index(1).RawString()) {
+			continue
+		}
+		if dest := pdfNameTreeLookup(kid, key, depth+1); !dest.IsNull() {
+			return dest
+		}
+	}
+	return pdf.Value{}
+}
+
+// markOutlineHeadings turns the title of every outline section into a
+// Markdown heading on the page it starts on, so chunks break and are
+// labelled at the sections of the outline. The line showing the title is
+// used when the page has one; otherwise the heading is added at the top.
+func markOutlineHeadings(pages []models.DocumentPage, outline []models.DocumentSection) []models.DocumentPage {
+	index := make(map[int]int, len(pages))
+	for i, page := range pages {
+		index[page.Number] = i
+	}
+	// added counts the headings put at the top of each page, keeping them in outline order
+	added := make(map[int]int)
+
+	for _, section := range FlattenSections(outline) {
+		i, ok := index[section.StartPage]
+		if !ok {
+			continue
+		}
+		heading := strings.Repeat("#", section.Level) + " " + section.Title
+		lines := strings.Split(pages[i].Text, "\n")
+		found := false
+		for j, line := range lines {
+			if !strings.HasPrefix(line, "#") && strings.EqualFold(strings.Join(strings.Fields(line), " "), section.Title) {
+				lines[j] = heading
+				found = true
+				break
+			}
+		}
+		if !found {
+			at := added[section.StartPage]
+			lines = append(lines[:at], append([]string{heading}, lines[at:]...)...)
+			added[section.StartPage]++
+		}
+		pages[i].Text = strings.Join(lines, "\n")
+	}
+	return pages
+}
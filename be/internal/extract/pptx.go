@@ -0,0 +1,254 @@
+package extract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
+)
+
+// PPTX extracts PowerPoint slide decks
+type PPTX struct{}
+
+// Extract validates a PowerPoint deck held in memory and extracts it
+// slide by slide. Every slide becomes a page numbered as in PowerPoint that
+// starts with its title as a "#" heading, followed by its text, tables and
+// speaker notes.
+func (PPTX) Extract(content []byte, filename string) (*Document, error) {
+	if int64(len(content)) > MaxFileSize {
+		return nil, fmt.Errorf("file too large (max allowed: %d bytes)", MaxFileSize)
+	}
+
+	zr, err := openZipPackage(content)
+	if err != nil {
+		return nil, fmt.Errorf("file does not appear to be a valid PPTX: %s", filename)
+	}
+	if err := validateOOXMLContent(zr, filename, "PPTX", "ppt/presentation.xml"); err != nil {
+		return nil, err
+	}
+
+	slides, err := pptxSlideParts(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	var pages []models.DocumentPage
+	for i, part := range slides {
+		slideText, err := pptxSlideText(zr, part, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if slideText == "" {
+			continue
+		}
+		text.WriteString(slideText)
+		text.WriteString("\n\n")
+		pages = append(pages, models.DocumentPage{Number: i + 1, Text: slideText})
+	}
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no text content found in PPTX")
+	}
+	return &Document{Text: strings.TrimSpace(text.String()), Pages: pages, Metadata: ooxmlMetadata(zr)}, nil
+}
+
+// pptxSlideParts returns the package paths of the slides in presentation order
+func pptxSlideParts(zr *zipPackage) ([]string, error) {
+	presentation, err := readZipXML(zr, "ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+	rels, err := readRelationships(zr, "ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	list := presentation.child("sldIdLst")
+	if list == nil {
+		return nil, nil
+	}
+	for i := range list.Nodes {
+		rel, ok := rels[list.Nodes[i].relationshipID()]
+		if !ok {
+			return nil, fmt.Errorf("presentation refers to a missing slide")
+		}
+		parts = append(parts, rel.Target)
+	}
+	return parts, nil
+}
+
+// pptxSlideText renders one slide, or returns "" for a hidden or empty slide
+func pptxSlideText(zr *zipPackage, part string, number int) (string, error) {
+	slide, err := readZipXML(zr, part)
+	if err != nil {
+		return "", err
+	}
+	if slide == nil {
+		return "", fmt.Errorf("slide %d is missing from the presentation", number)
+	}
+	if slide.attr("show") == "0" {
+		return "", nil
+	}
+
+	w := &pptxWriter{}
+	w.writeShapes(slide.child("cSld").child("spTree"))
+
+	notes, err := pptxNotesText(zr, part)
+	if err != nil {
+		return "", err
+	}
+
+	body := w.String()
+	if w.title == "" && body == "" && notes == "" {
+		return "", nil
+	}
+
+	title := w.title
+	if title == "" {
+		title = "Slide " + strconv.Itoa(number)
+	}
+	text := "# " + title
+	if body != "" {
+		text += "\n\n" + body
+	}
+	if notes != "" {
+		text += "\n\nSpeaker notes:\n" + notes
+	}
+	return text, nil
+}
+
+// pptxNotesText returns the speaker notes of a slide, or "" when it has none
+func pptxNotesText(zr *zipPackage, slidePart string) (string, error) {
+	rels, err := readRelationships(zr, slidePart)
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range rels {
+		if !strings.HasSuffix(rel.Type, "/notesSlide") {
+			continue
+		}
+		notes, err := readZipXML(zr, rel.Target)
+		if err != nil || notes == nil {
+			return "", err
+		}
+		w := &pptxWriter{notes: true}
+		w.writeShapes(notes.child("cSld").child("spTree"))
+		return w.String(), nil
+	}
+	return "", nil
+}
+
+// pptxWriter renders the shapes of a slide as structured text
+type pptxWriter struct {
+	// notes restricts output to the notes placeholder of a notes slide
+	notes bool
+	title string
+
+	blockWriter
+}
+
+func (w *pptxWriter) writeShapes(tree *xmlNode) {
+	if tree == nil {
+		return
+	}
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		switch node.XMLName.Local {
+		case "sp":
+			w.writeShape(node)
+		case "grpSp":
+			w.writeShapes(node)
+		case "graphicFrame":
+			if w.notes {
+				continue
+			}
+			tbl := node.child("graphic").child("graphicData").child("tbl")
+			if tbl == nil {
+				continue
+			}
+			if table := tableMarkdown(tbl); table != "" {
+				w.write(table, false)
+			}
+		case "AlternateContent":
+			w.writeShapes(node.child("Choice"))
+		}
+	}
+}
+
+func (w *pptxWriter) writeShape(sp *xmlNode) {
+	ph := sp.child("nvSpPr").child("nvPr").child("ph")
+	phType := ph.attrOrEmpty("type")
+	txBody := sp.child("txBody")
+
+	if w.notes {
+		// The other placeholders of a notes slide hold the slide image,
+		// header and page number
+		if phType == "body" {
+			w.writeTextBody(txBody, false)
+		}
+		return
+	}
+
+	switch phType {
+	case "title", "ctrTitle":
+		if w.title == "" {
+			w.title = ooxmlText(txBody)
+			return
+		}
+	case "sldNum", "dt", "ftr", "hdr":
+		return
+	}
+	// Content placeholders are bulleted unless a paragraph turns it off
+	bulleted := ph != nil && (phType == "" || phType == "body" || phType == "obj")
+	w.writeTextBody(txBody, bulleted)
+}
+
+// writeTextBody writes the paragraphs of a text body, indenting bullets and
+// numbers by their outline level
+func (w *pptxWriter) writeTextBody(txBody *xmlNode, bulleted bool) {
+	if txBody == nil {
+		return
+	}
+	counts := make([]int, 9)
+	for i := range txBody.Nodes {
+		p := &txBody.Nodes[i]
+		if p.XMLName.Local != "p" {
+			continue
+		}
+		text := ooxmlText(p)
+		if text == "" {
+			continue
+		}
+
+		pPr := p.child("pPr")
+		level, err := strconv.Atoi(pPr.attrOrEmpty("lvl"))
+		if err != nil || level < 0 || level > 8 {
+			level = 0
+		}
+
+		marker := ""
+		switch {
+		case pPr.child("buNone") != nil:
+		case pPr.child("buAutoNum") != nil:
+			counts[level]++
+			for j := level + 1; j < len(counts); j++ {
+				counts[j] = 0
+			}
+			start, err := strconv.Atoi(pPr.child("buAutoNum").attr("startAt"))
+			if err != nil {
+				start = 1
+			}
+			marker = strconv.Itoa(start+counts[level]-1) + ") "
+		case pPr.child("buChar") != nil || bulleted:
+			marker = "- "
+		}
+		if marker == "" {
+			w.write(text, false)
+			continue
+		}
+		w.write(strings.Repeat("  ", level)+marker+text, true)
+	}
+}
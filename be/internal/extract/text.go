@@ -0,0 +1,310 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// decodeText validates that content is text rather than binary data and
+// converts it to UTF-8, guessing the encoding from a byte order mark or,
+// for HTML, a <meta charset> declaration
+func decodeText(content []byte, contentType, filename string) (string, error) {
+	if int64(len(content)) > MaxFileSize {
+		return "", fmt.Errorf("file too large (max allowed: %d bytes)", MaxFileSize)
+	}
+	// UTF-16 text is full of NUL bytes, so it is recognized by its byte
+	// order mark and checked for binary data once decoded
+	utf16 := bytes.HasPrefix(content, []byte{0xFF, 0xFE}) || bytes.HasPrefix(content, []byte{0xFE, 0xFF})
+	if !utf16 && bytes.IndexByte(content[:min(len(content), 8192)], 0) >= 0 {
+		return "", fmt.Errorf("file does not appear to be a text document: %s", filename)
+	}
+
+	enc, _, _ := charset.DetermineEncoding(content, contentType)
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", filename, err)
+	}
+	if utf16 && bytes.IndexByte(decoded[:min(len(decoded), 8192)], 0) >= 0 {
+		return "", fmt.Errorf("file does not appear to be a text document: %s", filename)
+	}
+
+	text := strings.TrimPrefix(string(decoded), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// Text extracts plain text documents
+type Text struct{}
+
+// Extract reads a plain text document
+func (Text) Extract(content []byte, filename string) (*Document, error) {
+	text, err := decodeText(content, "text/plain", filename)
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("no text content found in %s", filename)
+	}
+	return &Document{Text: text, Pages: singlePage(text)}, nil
+}
+
+// Markdown extracts Markdown documents
+type Markdown struct{}
+
+// Extract reads a Markdown document, stripping its markup but keeping
+// headings as "#" lines and list items with their bullets so the document
+// can be chunked along its structure
+func (Markdown) Extract(content []byte, filename string) (*Document, error) {
+	source, err := decodeText(content, "text/plain", filename)
+	if err != nil {
+		return nil, err
+	}
+	text := markdownText(source)
+	if text == "" {
+		return nil, fmt.Errorf("no text content found in %s", filename)
+	}
+	return &Document{Text: text, Pages: singlePage(text), Metadata: frontMatterMetadata(source)}, nil
+}
+
+var (
+	mdATXHeading      = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	mdSetextUnderline = regexp.MustCompile(`^ {0,3}(?:=+|-+)\s*$`)
+	mdFence           = regexp.MustCompile("^ {0,3}(```|~~~)")
+	mdRule            = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdBullet          = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdOrdered         = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	mdQuote           = regexp.MustCompile(`^ {0,3}>\s?`)
+	mdRefDefinition   = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*\S+`)
+
+	mdCodeSpan = regexp.MustCompile("(`+)(.+?)(`+)")
+	mdEscape   = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!|>~])")
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\](?:\([^)]*\)|\[[^\]]*\])`)
+	mdAutolink = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	mdHTMLTag  = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	mdStrong   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdStar     = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	mdUnder    = regexp.MustCompile(`(^|\W)_(\S(?:[^_]*?\S)?)_(\W|$)`)
+	mdStrike   = regexp.MustCompile(`~~(.+?)~~`)
+)
+
+// markdownText strips Markdown markup line by line. Headings are normalized
+// to "#" lines, bullets to "- " and numbered items to "1) " so numbered list
+// items are not mistaken for numbered headings; tables are kept as they are,
+// and code blocks keep their contents between their fences so lines such as
+// "# comment" are not read as headings.
+func markdownText(source string) string {
+	lines := strings.Split(source, "\n")
+	lines = skipFrontMatter(lines)
+
+	var out []string
+	fence := ""
+	inComment, quoted := false, false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				out = append(out, fence, "")
+				fence = ""
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			out = append(out, "", fence)
+			continue
+		}
+
+		line, inComment = stripHTMLComments(line, inComment)
+		if inComment && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if mdRefDefinition.MatchString(line) {
+			continue
+		}
+		isQuote := mdQuote.MatchString(line)
+		for mdQuote.MatchString(line) {
+			line = mdQuote.ReplaceAllString(line, "")
+		}
+		// A block quote starts a paragraph of its own
+		if isQuote && !quoted {
+			out = append(out, "")
+		}
+		quoted = isQuote
+
+		// A paragraph line underlined with === or --- is a heading
+		if i+1 < len(lines) && strings.TrimSpace(line) != "" && mdSetextUnderline.MatchString(lines[i+1]) &&
+			!mdBullet.MatchString(line) && !mdOrdered.MatchString(line) && !strings.HasPrefix(strings.TrimSpace(line), "|") {
+			level := "#"
+			if strings.Contains(lines[i+1], "-") {
+				level = "##"
+			}
+			out = append(out, level+" "+mdInline(strings.TrimSpace(line)))
+			i++
+			continue
+		}
+		if mdRule.MatchString(line) {
+			out = append(out, "")
+			continue
+		}
+
+		switch {
+		case mdATXHeading.MatchString(line):
+			m := mdATXHeading.FindStringSubmatch(line)
+			out = append(out, m[1]+" "+mdInline(m[2]))
+		case mdBullet.MatchString(line):
+			m := mdBullet.FindStringSubmatch(line)
+			out = append(out, m[1]+"- "+mdInline(m[2]))
+		case mdOrdered.MatchString(line):
+			m := mdOrdered.FindStringSubmatch(line)
+			out = append(out, m[1]+m[2]+") "+mdInline(m[3]))
+		default:
+			out = append(out, mdInline(line))
+		}
+	}
+
+	text := strings.Join(out, "\n")
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return strings.TrimSpace(text)
+}
+
+// skipFrontMatter drops a leading YAML front matter block
+func skipFrontMatter(lines []string) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		if end := strings.TrimSpace(lines[i]); end == "---" || end == "..." {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// frontMatterMetadata reads the title, author and language set in the YAML
+// front matter of a Markdown document
+func frontMatterMetadata(source string) Metadata {
+	var metadata Metadata
+	lines := strings.Split(source, "\n")
+	if len(skipFrontMatter(lines)) == len(lines) {
+		return metadata
+	}
+	for _, line := range lines[1:] {
+		if end := strings.TrimSpace(line); end == "---" || end == "..." {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = cleanMetadata(strings.Trim(strings.TrimSpace(value), `"'`))
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			metadata.Title = value
+		case "author":
+			metadata.Author = value
+		case "lang", "language":
+			metadata.Language = value
+		}
+	}
+	return metadata
+}
+
+// stripHTMLComments removes <!-- --> comments from a line; inComment carries
+// a comment that spans lines
+func stripHTMLComments(line string, inComment bool) (string, bool) {
+	var b strings.Builder
+	for {
+		if inComment {
+			end := strings.Index(line, "-->")
+			if end < 0 {
+				return b.String(), true
+			}
+			line = line[end+3:]
+			inComment = false
+		}
+		start := strings.Index(line, "<!--")
+		if start < 0 {
+			b.WriteString(line)
+			return b.String(), false
+		}
+		b.WriteString(line[:start])
+		line = line[start+4:]
+		inComment = true
+	}
+}
+
+// mdInline strips inline Markdown: links and images keep their text, and
+// emphasis, strikethrough and inline HTML are removed. Code spans are kept
+// verbatim without their backticks.
+func mdInline(line string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdCodeSpan.FindAllStringSubmatchIndex(line, -1) {
+		// Only a closing run as long as the opening one ends a code span
+		if m[3]-m[2] != m[7]-m[6] {
+			continue
+		}
+		b.WriteString(mdStripInline(line[last:m[0]]))
+		b.WriteString(strings.TrimSpace(line[m[4]:m[5]]))
+		last = m[1]
+	}
+	b.WriteString(mdStripInline(line[last:]))
+	return b.String()
+}
+
+// Escaped punctuation is parked in the private use area at mdEscapeBase
+// while emphasis is stripped. Private use runes already in the text are
+// preceded by mdLiteral so they come back unchanged.
+const (
+	mdEscapeBase = 0xE000
+	mdLiteral    = mdEscapeBase + 128
+)
+
+func mdStripInline(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= mdEscapeBase && r <= mdLiteral {
+			b.WriteRune(mdLiteral)
+		}
+		b.WriteRune(r)
+	}
+	text = mdEscape.ReplaceAllStringFunc(b.String(), func(s string) string {
+		return string(rune(mdEscapeBase + int(s[1])))
+	})
+	text = mdImage.ReplaceAllString(text, "$1")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdAutolink.ReplaceAllString(text, "$1")
+	text = mdHTMLTag.ReplaceAllString(text, "")
+	text = mdStrong.ReplaceAllString(text, "$1$2")
+	text = mdStar.ReplaceAllString(text, "$1")
+	text = mdUnder.ReplaceAllString(text, "$1$2$3")
+	text = mdStrike.ReplaceAllString(text, "$1")
+
+	b.Reset()
+	literal := false
+	for _, r := range text {
+		switch {
+		case literal:
+			b.WriteRune(r)
+			literal = false
+		case r == mdLiteral:
+			literal = true
+		case r >= mdEscapeBase && r < mdLiteral:
+			b.WriteRune(r - mdEscapeBase)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
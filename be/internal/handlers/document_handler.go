@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"pbkk-quizlit-backend/internal/middleware"
+	"pbkk-quizlit-backend/internal/models"
+	"pbkk-quizlit-backend/internal/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type DocumentHandler struct {
+	documentService *services.DocumentService
+	fileService     *services.FileService
+	aiService       *services.AIService
+	chatService     *services.ChatService
+	logger          *logrus.Logger
+}
+
+func NewDocumentHandler(documentService *services.DocumentService, fileService *services.FileService, aiService *services.AIService, chatService *services.ChatService) *DocumentHandler {
+	return &DocumentHandler{
+		documentService: documentService,
+		fileService:     fileService,
+		aiService:       aiService,
+		chatService:     chatService,
+		logger:          logrus.New(),
+	}
+}
+
+// UploadDocument stores an uploaded file without generating a quiz. A file
+// the user has uploaded before is not stored again; its document is returned.
+func (h *DocumentHandler) UploadDocument(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	if err := c.Request.ParseMultipartForm(maxUploadSize); err != nil {
+		h.logger.Errorf("Failed to parse multipart form: %v", err)
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Failed to parse form data",
+		})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "No file uploaded",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := h.fileService.ReadUpload(file, header)
+	if err != nil {
+		h.logger.Errorf("Failed to read file: %v", err)
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Failed to process uploaded file: " + err.Error(),
+		})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	doc, created, err := h.documentService.CreateFromFile(c.Request.Context(), userID, c.Request.FormValue("title"), header.Filename, data)
+	if err != nil {
+		h.logger.Errorf("Failed to store document: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to process uploaded file: " + err.Error(),
+		})
+		return
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "Document was already uploaded",
+			Data:    documentDetails(doc),
+		})
+		return
+	}
+	h.logger.Infof("Document %s uploaded by user %s", doc.ID, userID)
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Document uploaded successfully",
+		Data:    documentDetails(doc),
+	})
+}
+
+// ListDocuments returns the user's documents, newest first
+func (h *DocumentHandler) ListDocuments(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	documents, err := h.documentService.ListDocuments(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Errorf("Failed to list documents: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to retrieve documents",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Documents retrieved successfully",
+		Data: gin.H{
+			"documents": documents,
+			"total":     len(documents),
+		},
+	})
+}
+
+// GetDocument describes a document, listing its chapters or PDF outline
+// sections so they can be selected when generating a quiz from it
+func (h *DocumentHandler) GetDocument(c *gin.Context) {
+	doc, ok := getOwnedDocument(c, h.documentService, c.Param("id"), middleware.GetUserID(c))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Document retrieved successfully",
+		Data:    documentDetails(doc),
+	})
+}
+
+// DownloadDocument returns the original file of an uploaded document
+func (h *DocumentHandler) DownloadDocument(c *gin.Context) {
+	doc, ok := getOwnedDocument(c, h.documentService, c.Param("id"), middleware.GetUserID(c))
+	if !ok {
+		return
+	}
+
+	data, err := h.documentService.GetFile(c.Request.Context(), doc.ID)
+	if err != nil {
+		h.logger.Errorf("Failed to get document file: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to retrieve document file",
+		})
+		return
+	}
+	if data == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Document was created from pasted text and has no file",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.Filename}))
+	c.Data(http.StatusOK, doc.ContentType, data)
+}
+
+// documentDetails describes a document together with its chapters, empty
+// unless it is an e-book
+func documentDetails(doc *models.Document) gin.H {
+	chapters := services.ListChapters(doc.Pages)
+	if chapters == nil {
+		chapters = []models.DocumentChapter{}
+	}
+	return gin.H{
+		"document": doc,
+		"words":    len(strings.Fields(doc.Content)),
+		"chapters": chapters,
+	}
+}
+
+// GenerateStudyNotes returns study notes for a document, generating them on first request
+func (h *DocumentHandler) GenerateStudyNotes(c *gin.Context) {
+	id := c.Param("id")
+	userID := middleware.GetUserID(c)
+
+	doc, ok := getOwnedDocument(c, h.documentService, id, userID)
+	if !ok {
+		return
+	}
+
+	// Serve cached notes unless the client asks for a fresh copy
+	if c.Query("refresh") != "true" {
+		notes, found, err := h.documentService.GetStudyNotes(c.Request.Context(), doc.ID)
+		if err != nil {
+			h.logger.Warnf("Failed to load study notes of document %s: %v", doc.ID, err)
+		}
+		if found {
+			c.JSON(http.StatusOK, models.APIResponse{
+				Success: true,
+				Message: "Study notes retrieved successfully",
+				Data:    notes,
+			})
+			return
+		}
+	}
+
+	notes, err := h.aiService.GenerateStudyNotes(c.Request.Context(), doc)
+	if err != nil {
+		h.logger.Errorf("Failed to generate study notes: %v", err)
+		if respondIfRequestDone(c, err) || respondIfLLMUnavailable(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to generate study notes: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.documentService.SaveStudyNotes(c.Request.Context(), notes); err != nil {
+		h.logger.Warnf("Failed to save study notes of document %s: %v", doc.ID, err)
+	}
+
+	h.logger.Infof("Generated study notes for document %s", doc.ID)
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Study notes generated successfully",
+		Data:    notes,
+	})
+}
+
+// DeleteDocument removes a document, its study notes and its retrieval chunks
+func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
+	id := c.Param("id")
+	userID := middleware.GetUserID(c)
+
+	doc, ok := getOwnedDocument(c, h.documentService, id, userID)
+	if !ok {
+		return
+	}
+
+	if err := h.documentService.DeleteDocument(c.Request.Context(), doc.ID); err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "Document not found",
+			})
+			return
+		}
+		h.logger.Errorf("Failed to delete document: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete document",
+		})
+		return
+	}
+
+	h.logger.Infof("Document %s deleted by user %s", doc.ID, userID)
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Document deleted successfully",
+	})
+}
+
+// ChatWithDocument answers a question about a document from its content,
+// citing the pages the answer is based on. Questions the document does not
+// cover get a refusal rather than a guess. The exchange is added to the
+// user's conversation history for the document.
+func (h *DocumentHandler) ChatWithDocument(c *gin.Context) {
+	id := c.Param("id")
+	userID := middleware.GetUserID(c)
+
+	doc, ok := getOwnedDocument(c, h.documentService, id, userID)
+	if !ok {
+		return
+	}
+
+	var req models.DocumentChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Question) == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Question is required",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	question := &models.DocumentChatMessage{
+		DocumentID: doc.ID,
+		UserID:     userID,
+		Role:       "user",
+		Content:    strings.TrimSpace(req.Question),
+		CreatedAt:  time.Now(),
+	}
+
+	// Answer without earlier context rather than fail when history is unavailable
+	history, err := h.chatService.History(ctx, doc.ID, userID, services.ChatHistoryMessages)
+	if err != nil {
+		h.logger.Warnf("Failed to load chat history for document %s: %v", doc.ID, err)
+		history = nil
+	}
+
+	answer, err := h.aiService.AnswerDocumentQuestion(ctx, doc, question.Content, history)
+	if err != nil {
+		h.logger.Errorf("Failed to answer document question: %v", err)
+		if respondIfRequestDone(c, err) || respondIfLLMUnavailable(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to answer question: " + err.Error(),
+		})
+		return
+	}
+
+	// The answer is worth returning even if the client has stopped waiting for the save
+	if err := h.chatService.SaveExchange(context.WithoutCancel(ctx), question, answer); err != nil {
+		h.logger.Warnf("Failed to save chat history for document %s: %v", doc.ID, err)
+	}
+
+	message := "Answer generated successfully"
+	if answer.Refused {
+		message = "The document does not cover this question"
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data: gin.H{
+			"question": question,
+			"answer":   answer,
+		},
+	})
+}
+
+// GetChatHistory returns the user's conversation with a document, oldest first
+func (h *DocumentHandler) GetChatHistory(c *gin.Context) {
+	id := c.Param("id")
+	userID := middleware.GetUserID(c)
+
+	doc, ok := getOwnedDocument(c, h.documentService, id, userID)
+	if !ok {
+		return
+	}
+
+	messages, err := h.chatService.History(c.Request.Context(), doc.ID, userID, 0)
+	if err != nil {
+		h.logger.Errorf("Failed to get chat history: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to retrieve chat history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Chat history retrieved successfully",
+		Data: gin.H{
+			"messages": messages,
+			"total":    len(messages),
+		},
+	})
+}
+
+// getOwnedDocument loads a document and verifies it belongs to the user.
+// It writes the error response itself and reports whether the caller may continue.
+func getOwnedDocument(c *gin.Context, documentService *services.DocumentService, id, userID string) (*models.Document, bool) {
+	doc, err := documentService.GetDocument(c.Request.Context(), id)
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Document not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to retrieve document",
+		})
+		return nil, false
+	}
+
+	if doc.UserID != userID {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "You don't have permission to access this document",
+		})
+		return nil, false
+	}
+
+	return doc, true
+}
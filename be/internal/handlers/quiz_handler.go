@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"pbkk-quizlit-backend/internal/middleware"
@@ -16,22 +17,25 @@ import (
 )
 
 type QuizHandler struct {
-	quizService *services.QuizService
-	aiService   *services.AIService
-	fileService *services.FileService
-	logger      *logrus.Logger
+	quizService     *services.QuizService
+	aiService       *services.AIService
+	fileService     *services.FileService
+	documentService *services.DocumentService
+	logger          *logrus.Logger
 }
 
 const (
-	maxUploadSize = int64(20 << 20) // 20MB
+	maxUploadSize    = int64(20 << 20) // 20MB
+	minQuestionCount = services.MinQuestionCount
 )
 
-func NewQuizHandler(quizService *services.QuizService, aiService *services.AIService, fileService *services.FileService) *QuizHandler {
+func NewQuizHandler(quizService *services.QuizService, aiService *services.AIService, fileService *services.FileService, documentService *services.DocumentService) *QuizHandler {
 	return &QuizHandler{
-		quizService: quizService,
-		aiService:   aiService,
-		fileService: fileService,
-		logger:      logrus.New(),
+		quizService:     quizService,
+		aiService:       aiService,
+		fileService:     fileService,
+		documentService: documentService,
+		logger:          logrus.New(),
 	}
 }
 
@@ -96,16 +100,36 @@ func (h *QuizHandler) UploadFileAndGenerateQuiz(c *gin.Context) {
 		return
 	}
 
-	// Parse question count (default to 10 if not provided or invalid)
+	// Parse question count (default to 10 if not provided)
 	questionCount := 10
 	if questionCountStr != "" {
-		if count, err := strconv.Atoi(questionCountStr); err == nil && count >= 5 && count <= 15 {
-			questionCount = count
+		count, err := strconv.Atoi(strings.TrimSpace(questionCountStr))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "questionCount must be a whole number",
+			})
+			return
 		}
+		questionCount = count
+	}
+	if !h.checkQuestionLimit(c, questionCount, userID) {
+		return
+	}
+
+	defer file.Close()
+	data, err := h.fileService.ReadUpload(file, header)
+	if err != nil {
+		h.logger.Errorf("Failed to read file: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to process uploaded file: " + err.Error(),
+		})
+		return
 	}
 
-	// Process the uploaded file
-	content, err := h.fileService.ProcessUploadedFile(file, header)
+	// Store the upload so more quizzes can be generated from it later
+	doc, _, err := h.documentService.CreateFromFile(c.Request.Context(), userID, title, header.Filename, data)
 	if err != nil {
 		h.logger.Errorf("Failed to process file: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -115,18 +139,133 @@ func (h *QuizHandler) UploadFileAndGenerateQuiz(c *gin.Context) {
 		return
 	}
 
-	// Create quiz request
-	quizReq := &models.QuizGenerationRequest{
+	h.generateDocumentQuiz(c, doc, &models.DocumentQuizRequest{
 		Title:         title,
 		Description:   description,
-		Difficulty:    "medium",
 		QuestionCount: questionCount,
+		Pages:         c.Request.FormValue("pages"),
+		Sections:      c.Request.FormValue("sections"),
+		Chapters:      c.Request.FormValue("chapters"),
+		PerSection:    c.Request.FormValue("perSection") == "true",
+	}, userID)
+}
+
+// GenerateQuizFromDocument generates another quiz from a stored document,
+// optionally from only some of its pages, sections or chapters
+func (h *QuizHandler) GenerateQuizFromDocument(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	doc, ok := getOwnedDocument(c, h.documentService, c.Param("id"), userID)
+	if !ok {
+		return
 	}
 
-	// Generate quiz using AI
-	quiz, err := h.aiService.GenerateQuizFromContent(content, quizReq)
+	var req models.DocumentQuizRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+	if req.QuestionCount == 0 {
+		req.QuestionCount = 10
+	}
+	if !h.checkQuestionLimit(c, req.QuestionCount, userID) {
+		return
+	}
+
+	exists, err := h.quizService.QuizTitleExists(c.Request.Context(), req.Title, userID)
+	if err != nil {
+		h.logger.Errorf("Failed to check for duplicate title: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to validate quiz title",
+		})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("quiz with title '%s' already exists", req.Title),
+		})
+		return
+	}
+
+	h.generateDocumentQuiz(c, doc, &req, userID)
+}
+
+// generateDocumentQuiz generates, saves and returns a quiz from a stored
+// document, applying the page, section and chapter selection of req and
+// spreading the questions over its sections when req.PerSection is set.
+// The title and question count must already be validated.
+func (h *QuizHandler) generateDocumentQuiz(c *gin.Context, doc *models.Document, req *models.DocumentQuizRequest, userID string) {
+	content, pages := doc.Content, doc.Pages
+	var selection *models.PageSelection
+	var err error
+
+	// Quiz only the chosen pages and sections of a PDF
+	pagesSpec, sectionsSpec := strings.TrimSpace(req.Pages), strings.TrimSpace(req.Sections)
+	if pagesSpec != "" || sectionsSpec != "" {
+		pages, selection, err = services.SelectPages(doc, pagesSpec, sectionsSpec)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		content = services.PagesText(pages)
+	}
+
+	// Quiz only the chosen chapters of an e-book
+	if spec := strings.TrimSpace(req.Chapters); spec != "" {
+		pages, err = services.SelectChapters(pages, spec)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		content = services.PagesText(pages)
+	}
+
+	// Create quiz request
+	quizReq := &models.QuizGenerationRequest{
+		Title:         req.Title,
+		Description:   req.Description,
+		Difficulty:    "medium",
+		QuestionCount: req.QuestionCount,
+		UserID:        userID,
+		DocumentID:    doc.ID,
+		Pages:         pages,
+	}
+	// Part of a document is indexed on its own so the document's index,
+	// which chat relies on, keeps covering all of it
+	if len(pages) != len(doc.Pages) {
+		quizReq.DocumentID = ""
+	}
+
+	// Generate quiz using AI, section by section when asked to
+	var quiz *models.Quiz
+	if req.PerSection {
+		sections, splitErr := services.SplitSections(doc, pages, sectionsSpec)
+		if splitErr != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: splitErr.Error(),
+			})
+			return
+		}
+		quiz, err = h.aiService.GenerateQuizBySection(c.Request.Context(), sections, quizReq)
+	} else {
+		quiz, err = h.aiService.GenerateQuizFromContent(c.Request.Context(), content, quizReq)
+	}
 	if err != nil {
 		h.logger.Errorf("Failed to generate quiz: %v", err)
+		if respondIfRequestDone(c, err) || respondIfLLMUnavailable(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "Failed to generate quiz: " + err.Error(),
@@ -134,8 +273,10 @@ func (h *QuizHandler) UploadFileAndGenerateQuiz(c *gin.Context) {
 		return
 	}
 
-	// Save quiz (userID already retrieved earlier)
-	err = h.quizService.CreateQuiz(quiz, userID)
+	quiz.DocumentID = doc.ID
+	quiz.Filename = doc.Filename
+	quiz.Selection = selection
+	err = h.quizService.CreateQuiz(c.Request.Context(), quiz, userID)
 	if err != nil {
 		h.logger.Errorf("Failed to save quiz: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -153,52 +294,101 @@ func (h *QuizHandler) UploadFileAndGenerateQuiz(c *gin.Context) {
 	})
 }
 
-// GenerateQuizFromText handles quiz generation from text content
-func (h *QuizHandler) GenerateQuizFromText(c *gin.Context) {
-	var req models.GenerateQuizRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// InspectUpload extracts an uploaded file without generating a quiz and
+// describes it, listing the chapters of an e-book and the outline sections
+// of a PDF so they can be selected for UploadFileAndGenerateQuiz
+func (h *QuizHandler) InspectUpload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	if err := c.Request.ParseMultipartForm(maxUploadSize); err != nil {
+		h.logger.Errorf("Failed to parse multipart form: %v", err)
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
-			Message: "Invalid request format",
+			Message: "Failed to parse form data",
 		})
 		return
 	}
 
-	// Create quiz request
-	quizReq := &models.QuizGenerationRequest{
-		Title:         req.Title,
-		Description:   req.Description,
-		Difficulty:    "medium",
-		QuestionCount: req.QuestionCount,
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "No file uploaded",
+		})
+		return
 	}
 
-	if quizReq.QuestionCount == 0 {
-		quizReq.QuestionCount = 10
+	defer file.Close()
+	data, err := h.fileService.ReadUpload(file, header)
+	if err != nil {
+		h.logger.Errorf("Failed to read file: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to process uploaded file: " + err.Error(),
+		})
+		return
 	}
-
-	// Get user ID from context
-	userID := middleware.GetUserID(c)
-
-	// Check for duplicate title BEFORE generating quiz
-	exists, err := h.quizService.QuizTitleExists(c.Request.Context(), req.Title, userID)
+	extracted, err := h.fileService.ExtractFile(data, header.Filename)
 	if err != nil {
-		h.logger.Errorf("Failed to check for duplicate title: %v", err)
+		h.logger.Errorf("Failed to process file: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Message: "Failed to validate quiz title",
+			Message: "Failed to process uploaded file: " + err.Error(),
 		})
 		return
 	}
-	if exists {
-		c.JSON(http.StatusConflict, models.APIResponse{
+
+	chapters := services.ListChapters(extracted.Pages)
+	if chapters == nil {
+		chapters = []models.DocumentChapter{}
+	}
+	sections := extracted.Sections
+	if sections == nil {
+		sections = []models.DocumentSection{}
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "File inspected successfully",
+		Data: gin.H{
+			"filename": header.Filename,
+			"pages":    len(extracted.Pages),
+			"words":    len(strings.Fields(extracted.Text)),
+			"metadata": extracted.Metadata,
+			"chapters": chapters,
+			"sections": sections,
+		},
+	})
+}
+
+// GenerateQuizFromText handles quiz generation from text content
+func (h *QuizHandler) GenerateQuizFromText(c *gin.Context) {
+	req, quizReq, ok := h.bindTextQuizRequest(c)
+	if !ok {
+		return
+	}
+	userID := quizReq.UserID
+
+	// Keep the pasted content so study notes can be generated later
+	doc, err := h.documentService.CreateFromText(c.Request.Context(), userID, req.Title, req.Content)
+	if err != nil {
+		h.logger.Errorf("Failed to save document: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Message: fmt.Sprintf("quiz with title '%s' already exists", req.Title),
+			Message: "Failed to save document",
 		})
 		return
 	}
+	quizReq.DocumentID = doc.ID
 
 	// Generate quiz using AI
-	quiz, err := h.aiService.GenerateQuizFromContent(req.Content, quizReq)
+	quiz, err := h.aiService.GenerateQuizFromContent(c.Request.Context(), req.Content, quizReq)
+	if respondIfRequestDone(c, err) {
+		h.logger.Warnf("Request ended during quiz generation: %v", err)
+		return
+	}
+	if respondIfLLMUnavailable(c, err) {
+		h.logger.Warnf("LLM unavailable, rejecting quiz generation: %v", err)
+		return
+	}
 	if err != nil {
 		h.logger.Errorf("Failed to generate quiz with AI, using fallback: %v", err)
 		// Use fallback quiz generation
@@ -206,7 +396,8 @@ func (h *QuizHandler) GenerateQuizFromText(c *gin.Context) {
 	}
 
 	// Save quiz (userID already retrieved earlier)
-	err = h.quizService.CreateQuiz(quiz, userID)
+	quiz.DocumentID = doc.ID
+	err = h.quizService.CreateQuiz(c.Request.Context(), quiz, userID)
 	if err != nil {
 		h.logger.Errorf("Failed to save quiz: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -224,11 +415,152 @@ func (h *QuizHandler) GenerateQuizFromText(c *gin.Context) {
 	})
 }
 
+// GenerateQuizFromTextStream generates a quiz from text content and streams
+// it as server-sent events: a "question" event for each question as soon as
+// it is generated, then a "quiz" event with the saved quiz. Errors before the
+// first question are returned as regular JSON responses.
+func (h *QuizHandler) GenerateQuizFromTextStream(c *gin.Context) {
+	req, quizReq, ok := h.bindTextQuizRequest(c)
+	if !ok {
+		return
+	}
+	userID := quizReq.UserID
+
+	doc, err := h.documentService.CreateFromText(c.Request.Context(), userID, req.Title, req.Content)
+	if err != nil {
+		h.logger.Errorf("Failed to save document: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to save document",
+		})
+		return
+	}
+	quizReq.DocumentID = doc.ID
+
+	started := false
+	startStream := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// Stop reverse proxies from buffering the stream
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+	}
+
+	sent := 0
+	quiz, err := h.aiService.GenerateQuizFromContentStream(c.Request.Context(), req.Content, quizReq, func(q models.Question) {
+		startStream()
+		sent++
+		c.SSEvent("question", gin.H{
+			"index":    sent,
+			"total":    quizReq.QuestionCount,
+			"question": q,
+		})
+		c.Writer.Flush()
+	})
+	if respondIfRequestDone(c, err) {
+		h.logger.Warnf("Request ended during streamed quiz generation: %v", err)
+		return
+	}
+	if err != nil && !started && respondIfLLMUnavailable(c, err) {
+		h.logger.Warnf("LLM unavailable, rejecting quiz generation: %v", err)
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to generate quiz with AI, using fallback: %v", err)
+		quiz = h.generateFallbackQuiz(req.Content, quizReq)
+	}
+
+	quiz.DocumentID = doc.ID
+	if err := h.quizService.CreateQuiz(c.Request.Context(), quiz, userID); err != nil {
+		h.logger.Errorf("Failed to save quiz: %v", err)
+		if !started {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Failed to save quiz",
+			})
+			return
+		}
+		c.SSEvent("error", models.APIResponse{
+			Success: false,
+			Message: "Failed to save quiz",
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	h.logger.Infof("Successfully streamed quiz from text: %s (%d questions streamed)", quiz.ID, sent)
+	startStream()
+	c.SSEvent("quiz", models.APIResponse{
+		Success: true,
+		Message: "Quiz generated successfully",
+		Data:    quiz,
+	})
+	c.Writer.Flush()
+}
+
+// bindTextQuizRequest parses and validates a text generation request,
+// writing the error response itself when the request cannot proceed
+func (h *QuizHandler) bindTextQuizRequest(c *gin.Context) (*models.GenerateQuizRequest, *models.QuizGenerationRequest, bool) {
+	var req models.GenerateQuizRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return nil, nil, false
+	}
+
+	// Create quiz request
+	quizReq := &models.QuizGenerationRequest{
+		Title:         req.Title,
+		Description:   req.Description,
+		Difficulty:    "medium",
+		QuestionCount: req.QuestionCount,
+	}
+
+	if quizReq.QuestionCount == 0 {
+		quizReq.QuestionCount = 10
+	}
+
+	// Get user ID from context
+	userID := middleware.GetUserID(c)
+
+	if !h.checkQuestionLimit(c, quizReq.QuestionCount, userID) {
+		return nil, nil, false
+	}
+	quizReq.UserID = userID
+
+	// Check for duplicate title BEFORE generating quiz
+	exists, err := h.quizService.QuizTitleExists(c.Request.Context(), req.Title, userID)
+	if err != nil {
+		h.logger.Errorf("Failed to check for duplicate title: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to validate quiz title",
+		})
+		return nil, nil, false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("quiz with title '%s' already exists", req.Title),
+		})
+		return nil, nil, false
+	}
+
+	return &req, quizReq, true
+}
+
 // GetQuiz returns a specific quiz
 func (h *QuizHandler) GetQuiz(c *gin.Context) {
 	id := c.Param("id")
 
-	quiz, err := h.quizService.GetQuiz(id)
+	quiz, err := h.quizService.GetQuiz(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
@@ -249,7 +581,7 @@ func (h *QuizHandler) GetAllQuizzes(c *gin.Context) {
 	// Get user ID from auth middleware
 	userID := middleware.GetUserID(c)
 
-	quizzes, err := h.quizService.GetAllQuizzes(userID)
+	quizzes, err := h.quizService.GetAllQuizzes(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -278,7 +610,7 @@ func (h *QuizHandler) UpdateQuiz(c *gin.Context) {
 		return
 	}
 
-	err := h.quizService.UpdateQuiz(id, &updates)
+	err := h.quizService.UpdateQuiz(c.Request.Context(), id, &updates)
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
@@ -301,7 +633,7 @@ func (h *QuizHandler) DeleteQuiz(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
 	// Verify quiz ownership before deletion
-	quiz, err := h.quizService.GetQuiz(id)
+	quiz, err := h.quizService.GetQuiz(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
@@ -319,7 +651,7 @@ func (h *QuizHandler) DeleteQuiz(c *gin.Context) {
 		return
 	}
 
-	err = h.quizService.DeleteQuiz(id)
+	err = h.quizService.DeleteQuiz(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -330,12 +662,44 @@ func (h *QuizHandler) DeleteQuiz(c *gin.Context) {
 
 	h.logger.Infof("Quiz %s deleted by user %s", id, userID)
 
+	// Drop the source document's chunks once no quiz is generated from it,
+	// unless the document is still stored: it keeps its chunks for chat and
+	// later quizzes until it is deleted itself
+	if quiz.DocumentID != "" {
+		inUse, err := h.quizService.DocumentInUse(c.Request.Context(), quiz.DocumentID)
+		if err != nil {
+			h.logger.Warnf("Failed to check document usage: %v", err)
+		} else if !inUse {
+			_, err := h.documentService.GetDocument(c.Request.Context(), quiz.DocumentID)
+			if errors.Is(err, services.ErrDocumentNotFound) {
+				h.aiService.EvictDocument(c.Request.Context(), quiz.DocumentID)
+			} else if err != nil {
+				h.logger.Warnf("Failed to look up document %s: %v", quiz.DocumentID, err)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "Quiz deleted successfully",
 	})
 }
 
+// checkQuestionLimit rejects quizzes smaller than minQuestionCount or larger
+// than the user's configured maximum. It writes the error response itself
+// and reports whether the caller may continue.
+func (h *QuizHandler) checkQuestionLimit(c *gin.Context, questionCount int, userID string) bool {
+	limit := h.aiService.MaxQuestionsForUser(userID)
+	if questionCount < minQuestionCount || questionCount > limit {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("questionCount must be between %d and %d", minQuestionCount, limit),
+		})
+		return false
+	}
+	return true
+}
+
 // generateFallbackQuiz creates a quiz when AI service fails
 func (h *QuizHandler) generateFallbackQuiz(content string, req *models.QuizGenerationRequest) *models.Quiz {
 	h.logger.Info("Generating fallback quiz")
@@ -23,7 +23,7 @@ func (h *QuizHandler) GetQuizForTaking(c *gin.Context) {
 	}
 
 	// Get the quiz
-	quiz, err := h.quizService.GetQuiz(quizID)
+	quiz, err := h.quizService.GetQuiz(c.Request.Context(), quizID)
 	if err != nil {
 		h.logger.Errorf("Failed to get quiz: %v", err)
 		c.JSON(http.StatusNotFound, models.APIResponse{
@@ -57,7 +57,7 @@ func (h *QuizHandler) SubmitQuizAttempt(c *gin.Context) {
 	}
 
 	// Get the quiz with answers
-	quiz, err := h.quizService.GetQuiz(submission.QuizID)
+	quiz, err := h.quizService.GetQuiz(c.Request.Context(), submission.QuizID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
@@ -139,7 +139,7 @@ func (h *QuizHandler) GetQuizAttempt(c *gin.Context) {
 	}
 
 	// Get attempt from database
-	result, err := h.quizService.GetQuizAttempt(attemptID)
+	result, err := h.quizService.GetQuizAttempt(c.Request.Context(), attemptID)
 	if err != nil {
 		h.logger.Errorf("Failed to get quiz attempt: %v", err)
 		c.JSON(http.StatusNotFound, models.APIResponse{
@@ -158,7 +158,7 @@ func (h *QuizHandler) ListUserAttempts(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
 	// Get attempts from database
-	attempts, err := h.quizService.ListUserAttempts(userID)
+	attempts, err := h.quizService.ListUserAttempts(c.Request.Context(), userID)
 	if err != nil {
 		h.logger.Errorf("Failed to list user attempts: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -173,3 +173,119 @@ func (h *QuizHandler) ListUserAttempts(c *gin.Context) {
 		"total":    len(attempts),
 	})
 }
+
+// GenerateRemedialQuiz creates a practice quiz from the questions answered incorrectly in an attempt
+func (h *QuizHandler) GenerateRemedialQuiz(c *gin.Context) {
+	attemptID := c.Param("id")
+	if attemptID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Attempt ID is required",
+		})
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+
+	attempt, err := h.quizService.GetAttempt(c.Request.Context(), attemptID)
+	if err != nil {
+		h.logger.Errorf("Failed to get quiz attempt: %v", err)
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Attempt not found",
+		})
+		return
+	}
+
+	if attempt.UserID != userID {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "You don't have permission to access this attempt",
+		})
+		return
+	}
+
+	// Get the quiz with answers
+	quiz, err := h.quizService.GetQuiz(c.Request.Context(), attempt.QuizID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Quiz not found",
+		})
+		return
+	}
+
+	// Collect incorrectly answered questions, scored the same way as SubmitQuizAttempt
+	var missed []models.Question
+	for _, question := range quiz.Questions {
+		correctAnswerText := ""
+		if question.CorrectAnswer >= 0 && question.CorrectAnswer < len(question.Options) {
+			correctAnswerText = question.Options[question.CorrectAnswer]
+		}
+		if attempt.Answers[question.ID] != correctAnswerText {
+			missed = append(missed, question)
+		}
+	}
+
+	if len(missed) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "This attempt has no incorrect answers to practice",
+		})
+		return
+	}
+
+	// The source document may have been deleted; generation falls back to the questions
+	var doc *models.Document
+	if quiz.DocumentID != "" {
+		if d, err := h.documentService.GetDocument(c.Request.Context(), quiz.DocumentID); err == nil {
+			doc = d
+		}
+	}
+
+	practice, err := h.aiService.GenerateRemedialQuiz(c.Request.Context(), quiz, missed, doc)
+	if err != nil {
+		h.logger.Errorf("Failed to generate remedial quiz: %v", err)
+		if respondIfRequestDone(c, err) || respondIfLLMUnavailable(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to generate practice quiz: " + err.Error(),
+		})
+		return
+	}
+
+	// Titles are unique per user, so tag the practice quiz with its attempt
+	practice.Title = fmt.Sprintf("%s (attempt %s)", practice.Title, attempt.ID)
+	practice.SourceAttemptID = attempt.ID
+
+	exists, err := h.quizService.QuizTitleExists(c.Request.Context(), practice.Title, userID)
+	if err != nil {
+		h.logger.Errorf("Failed to check for duplicate title: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to validate quiz title",
+		})
+		return
+	}
+	if exists {
+		practice.Title = fmt.Sprintf("%s %s", practice.Title, time.Now().Format("2006-01-02 15:04:05"))
+	}
+
+	if err := h.quizService.CreateQuiz(c.Request.Context(), practice, userID); err != nil {
+		h.logger.Errorf("Failed to save practice quiz: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to save quiz",
+		})
+		return
+	}
+
+	h.logger.Infof("Created practice quiz %s from attempt %s", practice.ID, attempt.ID)
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Practice quiz generated successfully",
+		Data:    practice,
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pbkk-quizlit-backend/internal/models"
+	"pbkk-quizlit-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondIfLLMUnavailable answers with a Retry-After header when err means
+// the LLM call was rejected before reaching the model: 429 when the queue is
+// full, 503 while the upstream circuit breaker is open. It reports whether
+// it wrote a response.
+func respondIfLLMUnavailable(c *gin.Context, err error) bool {
+	var queueFull *services.QueueFullError
+	if errors.As(err, &queueFull) {
+		respondRetryLater(c, http.StatusTooManyRequests, queueFull.RetryAfter,
+			"The quiz generator is busy, please try again later")
+		return true
+	}
+
+	var circuitOpen *services.CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		respondRetryLater(c, http.StatusServiceUnavailable, circuitOpen.RetryAfter,
+			"The AI provider is temporarily unavailable, please try again later")
+		return true
+	}
+
+	return false
+}
+
+// respondIfRequestDone handles errors caused by the request context ending.
+// A client that went away gets no response; a passed deadline answers 504
+// if nothing has been written yet. It reports whether the caller should stop.
+func respondIfRequestDone(c *gin.Context, err error) bool {
+	ctxErr := c.Request.Context().Err()
+	if err == nil || ctxErr == nil {
+		return false
+	}
+
+	if errors.Is(ctxErr, context.DeadlineExceeded) && !c.Writer.Written() {
+		c.JSON(http.StatusGatewayTimeout, models.APIResponse{
+			Success: false,
+			Message: "The request took too long, please try again",
+		})
+		return true
+	}
+	c.Abort()
+	return true
+}
+
+func respondRetryLater(c *gin.Context, status int, after time.Duration, message string) {
+	retryAfter := int(math.Ceil(after.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(status, models.APIResponse{
+		Success: false,
+		Message: message,
+		Data:    gin.H{"retry_after_seconds": retryAfter},
+	})
+}
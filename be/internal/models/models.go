@@ -3,15 +3,31 @@ package models
 import "time"
 
 type Quiz struct {
-	ID             string     `json:"id"`
-	UserID         string     `json:"user_id,omitempty"`
-	Title          string     `json:"title"`
-	Description    string     `json:"description"`
-	Questions      []Question `json:"questions"`
-	Difficulty     string     `json:"difficulty"`
-	CreatedAt      time.Time  `json:"createdAt"`
-	UpdatedAt      time.Time  `json:"updatedAt"`
-	TotalQuestions int        `json:"totalQuestions"`
+	ID         string `json:"id"`
+	UserID     string `json:"user_id,omitempty"`
+	DocumentID string `json:"document_id,omitempty"`
+	// Practice quizzes link back to the quiz and attempt they were built from
+	ParentQuizID    string     `json:"parent_quiz_id,omitempty"`
+	SourceAttemptID string     `json:"source_attempt_id,omitempty"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Questions       []Question `json:"questions"`
+	Difficulty      string     `json:"difficulty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	TotalQuestions  int        `json:"totalQuestions"`
+	// Filename is the name of the uploaded source file, empty for pasted text
+	Filename string `json:"filename,omitempty"`
+	// Selection is the part of the source PDF the quiz was generated from,
+	// nil when it covers the whole document
+	Selection *PageSelection `json:"selection,omitempty"`
+}
+
+// PageSelection records the pages of a PDF a quiz was generated from, as
+// page ranges such as "40-55", and the outline sections that were picked
+type PageSelection struct {
+	Pages    string   `json:"pages"`
+	Sections []string `json:"sections,omitempty"`
 }
 
 type Question struct {
@@ -48,11 +64,159 @@ type GenerateQuizRequest struct {
 	QuestionCount int    `json:"questionCount,omitempty"`
 }
 
+// DocumentQuizRequest asks for a quiz from a stored document, optionally
+// limited to some of its pages, outline sections or e-book chapters, each
+// given as numbers and ranges such as "1,3-5"
+type DocumentQuizRequest struct {
+	Title         string `json:"title" binding:"required"`
+	Description   string `json:"description" binding:"required"`
+	QuestionCount int    `json:"questionCount,omitempty"`
+	Pages         string `json:"pages,omitempty"`
+	Sections      string `json:"sections,omitempty"`
+	Chapters      string `json:"chapters,omitempty"`
+	// PerSection spreads the questions over the document's sections or
+	// chapters instead of drawing them from the whole selection
+	PerSection bool `json:"perSection,omitempty"`
+}
+
 type QuizGenerationRequest struct {
 	Title         string `json:"title" binding:"required"`
 	Description   string `json:"description" binding:"required"`
 	Difficulty    string `json:"difficulty" binding:"required"`
 	QuestionCount int    `json:"questionCount,omitempty"`
+	// UserID identifies the requesting user for LLM scheduling and retrieval
+	UserID string `json:"-"`
+	// DocumentID scopes retrieval to the source document's chunks
+	DocumentID string `json:"-"`
+	// Pages is the source text split by page, used to chunk along page and heading boundaries
+	Pages []DocumentPage `json:"-"`
+}
+
+// QuizAttempt is a user's submitted answers for a quiz
+type QuizAttempt struct {
+	ID             string            `json:"id"`
+	QuizID         string            `json:"quiz_id"`
+	UserID         string            `json:"user_id"`
+	Score          int               `json:"score"`
+	TotalQuestions int               `json:"total_questions"`
+	Answers        map[string]string `json:"answers"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// Document is the source material a quiz was generated from: an uploaded
+// file or pasted text, stored so more quizzes can be generated from it
+type Document struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id,omitempty"`
+	Title       string `json:"title"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Size is the size of the original file in bytes, 0 for pasted text
+	Size int64 `json:"size"`
+	// Hash is the hex SHA-256 of the original file, or of pasted text
+	Hash      string `json:"sha256"`
+	PageCount int    `json:"page_count"`
+	Content   string `json:"-"`
+	// Pages keeps the extracted text page by page with its line breaks
+	Pages []DocumentPage `json:"-"`
+	// Sections is the outline of a PDF as a section tree
+	Sections []DocumentSection `json:"sections,omitempty"`
+	// QuizCount is the number of quizzes generated from the document
+	QuizCount int       `json:"quiz_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DocumentPage is the text of one page of a document. Number is 1-based;
+// 0 means the text has no page structure, such as pasted content. For
+// e-books a page is a chapter and Title is the chapter's title.
+type DocumentPage struct {
+	Number int    `json:"number"`
+	Title  string `json:"title,omitempty"`
+	Text   string `json:"text"`
+}
+
+// DocumentChapter describes a chapter of an uploaded e-book, so users can
+// pick the chapters to generate a quiz from
+type DocumentChapter struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Words  int    `json:"words"`
+}
+
+// DocumentSection is an entry of a PDF's outline (bookmarks) with the pages
+// it spans and its subsections. Level is 1 for top-level entries.
+type DocumentSection struct {
+	Number    int               `json:"number"`
+	Title     string            `json:"title"`
+	Level     int               `json:"level"`
+	StartPage int               `json:"start_page"`
+	EndPage   int               `json:"end_page"`
+	Children  []DocumentSection `json:"children,omitempty"`
+}
+
+// DocumentChunk is a piece of a document stored with its embedding for retrieval
+type DocumentChunk struct {
+	ID         string
+	DocumentID string
+	UserID     string
+	ChunkIndex int
+	// Page is the 1-based source page, 0 when unknown
+	Page int
+	// Section is the heading the chunk falls under, if one was detected
+	Section   string
+	Content   string
+	Embedding []float64
+	// Score is the similarity to the query when returned from a search
+	Score float64
+}
+
+// StudyNotes are structured review notes generated from a document
+type StudyNotes struct {
+	DocumentID  string         `json:"document_id"`
+	Title       string         `json:"title"`
+	Outline     []NoteSection  `json:"outline"`
+	KeyPoints   []string       `json:"key_points"`
+	Glossary    []GlossaryTerm `json:"glossary"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+type NoteSection struct {
+	Title       string        `json:"title"`
+	Summary     string        `json:"summary,omitempty"`
+	Subsections []NoteSection `json:"subsections,omitempty"`
+}
+
+type GlossaryTerm struct {
+	Term       string `json:"term"`
+	Definition string `json:"definition"`
+}
+
+// DocumentChatRequest is a question about a document
+type DocumentChatRequest struct {
+	Question string `json:"question" binding:"required"`
+}
+
+// DocumentChatMessage is one turn of a user's conversation with a document
+type DocumentChatMessage struct {
+	ID         string     `json:"id"`
+	DocumentID string     `json:"document_id"`
+	UserID     string     `json:"user_id,omitempty"`
+	Role       string     `json:"role"` // "user" or "assistant"
+	Content    string     `json:"content"`
+	Citations  []Citation `json:"citations,omitempty"`
+	// Refused is set on answers withheld because the document did not cover the question
+	Refused   bool      `json:"refused,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Citation links an answer to the document passage it is based on
+type Citation struct {
+	// Ref is the [n] marker used in the answer text
+	Ref     int     `json:"ref"`
+	Page    int     `json:"page,omitempty"`
+	Section string  `json:"section,omitempty"`
+	Excerpt string  `json:"excerpt"`
+	Score   float64 `json:"score"`
 }
 
 type APIResponse struct {
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"pbkk-quizlit-backend/internal/database"
+	"pbkk-quizlit-backend/internal/models"
+)
+
+// ChatRepository stores document chat history in the document_chat_messages table
+type ChatRepository struct{}
+
+func NewChatRepository() *ChatRepository {
+	return &ChatRepository{}
+}
+
+// SaveMessages inserts chat messages in one transaction
+func (r *ChatRepository) SaveMessages(ctx context.Context, messages ...*models.DocumentChatMessage) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, msg := range messages {
+		citations := msg.Citations
+		if citations == nil {
+			citations = []models.Citation{}
+		}
+		citationsJSON, err := json.Marshal(citations)
+		if err != nil {
+			return fmt.Errorf("failed to marshal citations: %w", err)
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO document_chat_messages (id, document_id, user_id, role, content, citations, refused, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7, $8)`,
+			msg.ID, msg.DocumentID, msg.UserID, msg.Role, msg.Content, string(citationsJSON), msg.Refused, msg.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save chat message: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListMessages returns the latest limit messages of a user's conversation
+// with a document, oldest first. A limit of 0 returns the whole history.
+func (r *ChatRepository) ListMessages(ctx context.Context, documentID, userID string, limit int) ([]models.DocumentChatMessage, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT id, document_id, user_id, role, content, citations, refused, created_at
+		 FROM (
+		   SELECT * FROM document_chat_messages
+		   WHERE document_id = $1 AND user_id = $2
+		   ORDER BY created_at DESC, role DESC
+		   LIMIT NULLIF($3, 0)
+		 ) latest
+		 ORDER BY created_at, role DESC`,
+		documentID, userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.DocumentChatMessage{}
+	for rows.Next() {
+		var msg models.DocumentChatMessage
+		var citationsJSON []byte
+		if err := rows.Scan(&msg.ID, &msg.DocumentID, &msg.UserID, &msg.Role, &msg.Content, &citationsJSON, &msg.Refused, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan chat message: %w", err)
+		}
+		if len(citationsJSON) > 0 {
+			if err := json.Unmarshal(citationsJSON, &msg.Citations); err != nil {
+				return nil, fmt.Errorf("failed to parse citations: %w", err)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat messages: %w", err)
+	}
+	return messages, nil
+}
+
+// DeleteDocumentMessages removes every conversation about a document
+func (r *ChatRepository) DeleteDocumentMessages(ctx context.Context, documentID string) (int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database connection not initialized")
+	}
+
+	tag, err := db.Exec(ctx, `DELETE FROM document_chat_messages WHERE document_id = $1`, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete chat messages: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
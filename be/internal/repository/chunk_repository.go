@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"pbkk-quizlit-backend/internal/database"
+	"pbkk-quizlit-backend/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ChunkRepository stores RAG chunks and embeddings in the document_chunks table
+type ChunkRepository struct{}
+
+func NewChunkRepository() *ChunkRepository {
+	return &ChunkRepository{}
+}
+
+// TableExists reports whether the document_chunks migration has been applied
+func (r *ChunkRepository) TableExists(ctx context.Context) (bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	var exists bool
+	err := db.QueryRow(ctx, `SELECT to_regclass('document_chunks') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check document_chunks table: %w", err)
+	}
+	return exists, nil
+}
+
+// HasVectorColumn reports whether the optional pgvector column is present
+func (r *ChunkRepository) HasVectorColumn(ctx context.Context) (bool, error) {
+	return r.hasColumn(ctx, "embedding_vec")
+}
+
+// HasLocationColumns reports whether the page and section columns are present
+func (r *ChunkRepository) HasLocationColumns(ctx context.Context) (bool, error) {
+	hasPage, err := r.hasColumn(ctx, "page")
+	if err != nil || !hasPage {
+		return false, err
+	}
+	return r.hasColumn(ctx, "section")
+}
+
+func (r *ChunkRepository) hasColumn(ctx context.Context, column string) (bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return false, fmt.Errorf("database connection not initialized")
+	}
+
+	var exists bool
+	err := db.QueryRow(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'document_chunks' AND column_name = $1
+		)`,
+		column,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s column: %w", column, err)
+	}
+	return exists, nil
+}
+
+// UpsertChunks inserts or replaces chunks by ID. withVector also fills the pgvector column.
+func (r *ChunkRepository) UpsertChunks(ctx context.Context, chunks []models.DocumentChunk, withVector bool) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := upsertChunks(ctx, tx, chunks, withVector); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ReplaceDocumentChunks deletes a document's chunks and inserts chunks in
+// their place in one transaction, so readers never see the document
+// without chunks
+func (r *ChunkRepository) ReplaceDocumentChunks(ctx context.Context, documentID string, chunks []models.DocumentChunk, withVector bool) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM document_chunks WHERE document_id = $1`, documentID); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	if err := upsertChunks(ctx, tx, chunks, withVector); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// upsertChunks writes chunks inside tx
+func upsertChunks(ctx context.Context, tx pgx.Tx, chunks []models.DocumentChunk, withVector bool) error {
+	var err error
+	for _, ch := range chunks {
+		if withVector {
+			_, err = tx.Exec(ctx,
+				`INSERT INTO document_chunks (id, document_id, user_id, chunk_index, page, section, content, embedding, embedding_vec)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::vector)
+				 ON CONFLICT (id) DO UPDATE SET
+				   document_id = EXCLUDED.document_id, user_id = EXCLUDED.user_id, chunk_index = EXCLUDED.chunk_index,
+				   page = EXCLUDED.page, section = EXCLUDED.section,
+				   content = EXCLUDED.content, embedding = EXCLUDED.embedding, embedding_vec = EXCLUDED.embedding_vec`,
+				ch.ID, ch.DocumentID, ch.UserID, ch.ChunkIndex, ch.Page, ch.Section, ch.Content, ch.Embedding, vectorLiteral(ch.Embedding),
+			)
+		} else {
+			_, err = tx.Exec(ctx,
+				`INSERT INTO document_chunks (id, document_id, user_id, chunk_index, page, section, content, embedding)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				 ON CONFLICT (id) DO UPDATE SET
+				   document_id = EXCLUDED.document_id, user_id = EXCLUDED.user_id, chunk_index = EXCLUDED.chunk_index,
+				   page = EXCLUDED.page, section = EXCLUDED.section,
+				   content = EXCLUDED.content, embedding = EXCLUDED.embedding`,
+				ch.ID, ch.DocumentID, ch.UserID, ch.ChunkIndex, ch.Page, ch.Section, ch.Content, ch.Embedding,
+			)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to upsert chunk %s: %w", ch.ID, err)
+		}
+	}
+	return nil
+}
+
+// ListChunks returns stored chunks with their embeddings. An empty userID or
+// documentIDs matches every user or document.
+func (r *ChunkRepository) ListChunks(ctx context.Context, userID string, documentIDs []string) ([]models.DocumentChunk, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT id, document_id, user_id, chunk_index, page, section, content, embedding
+		 FROM document_chunks
+		 WHERE ($1 = '' OR user_id = $1)
+		   AND (cardinality($2::text[]) = 0 OR document_id = ANY($2::text[]))
+		 ORDER BY document_id, chunk_index`,
+		userID, nonNilStrings(documentIDs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []models.DocumentChunk
+	for rows.Next() {
+		var ch models.DocumentChunk
+		if err := rows.Scan(&ch.ID, &ch.DocumentID, &ch.UserID, &ch.ChunkIndex, &ch.Page, &ch.Section, &ch.Content, &ch.Embedding); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		chunks = append(chunks, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// SearchChunks returns the k chunks nearest to query by cosine distance using pgvector,
+// filtered like ListChunks. Only chunks embedded with the same dimensions as query are considered.
+func (r *ChunkRepository) SearchChunks(ctx context.Context, query []float64, k int, userID string, documentIDs []string) ([]models.DocumentChunk, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT id, document_id, user_id, chunk_index, page, section, content, embedding,
+		        1 - (embedding_vec <=> $1::vector) AS score
+		 FROM document_chunks
+		 WHERE embedding_vec IS NOT NULL AND vector_dims(embedding_vec) = $2
+		   AND ($4 = '' OR user_id = $4)
+		   AND (cardinality($5::text[]) = 0 OR document_id = ANY($5::text[]))
+		 ORDER BY embedding_vec <=> $1::vector
+		 LIMIT $3`,
+		vectorLiteral(query), len(query), k, userID, nonNilStrings(documentIDs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []models.DocumentChunk
+	for rows.Next() {
+		var ch models.DocumentChunk
+		if err := rows.Scan(&ch.ID, &ch.DocumentID, &ch.UserID, &ch.ChunkIndex, &ch.Page, &ch.Section, &ch.Content, &ch.Embedding, &ch.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		chunks = append(chunks, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// DeleteDocumentChunks removes every chunk of a document
+func (r *ChunkRepository) DeleteDocumentChunks(ctx context.Context, documentID string) (int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database connection not initialized")
+	}
+
+	tag, err := db.Exec(ctx, `DELETE FROM document_chunks WHERE document_id = $1`, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// nonNilStrings turns a nil slice into an empty one so it encodes as '{}' rather than NULL
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// vectorLiteral formats an embedding in pgvector's text format, e.g. [0.1,0.2]
+func vectorLiteral(v []float64) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
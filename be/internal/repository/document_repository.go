@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pbkk-quizlit-backend/internal/database"
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrDocumentNotFound is returned when no document has the requested ID or hash
+var ErrDocumentNotFound = errors.New("document not found")
+
+// DocumentRepository stores uploaded documents in the documents table
+type DocumentRepository struct{}
+
+func NewDocumentRepository() *DocumentRepository {
+	return &DocumentRepository{}
+}
+
+// documentColumns are the columns read into a models.Document; the file
+// itself is only read by GetFileData
+const documentColumns = `id::text, user_id, title, filename, content_type, file_size, sha256,
+		content, pages, sections, page_count, created_at,
+		(SELECT COUNT(*) FROM quizzes q WHERE q.document_id = documents.id)`
+
+// CreateDocument inserts a document together with its original file, which
+// may be nil for pasted text
+func (r *DocumentRepository) CreateDocument(ctx context.Context, doc *models.Document, fileData []byte) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	pagesJSON, err := json.Marshal(nonNilSlice(doc.Pages))
+	if err != nil {
+		return fmt.Errorf("failed to marshal pages: %w", err)
+	}
+	sectionsJSON, err := json.Marshal(nonNilSlice(doc.Sections))
+	if err != nil {
+		return fmt.Errorf("failed to marshal sections: %w", err)
+	}
+
+	_, err = db.Exec(ctx,
+		`INSERT INTO documents (id, user_id, title, filename, content_type, file_size, file_data, sha256,
+		                        content, pages, sections, page_count, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::jsonb, $11::jsonb, $12, $13)`,
+		doc.ID, doc.UserID, doc.Title, doc.Filename, doc.ContentType, doc.Size, fileData, doc.Hash,
+		doc.Content, string(pagesJSON), string(sectionsJSON), doc.PageCount, doc.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+	return nil
+}
+
+// GetDocument retrieves a document by ID
+func (r *DocumentRepository) GetDocument(ctx context.Context, id string) (*models.Document, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	row := db.QueryRow(ctx, `SELECT `+documentColumns+` FROM documents WHERE id = $1`, id)
+	return scanDocument(row)
+}
+
+// FindByHash retrieves the user's document with the given content hash
+func (r *DocumentRepository) FindByHash(ctx context.Context, userID, hash string) (*models.Document, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	row := db.QueryRow(ctx, `SELECT `+documentColumns+` FROM documents WHERE user_id = $1 AND sha256 = $2`, userID, hash)
+	return scanDocument(row)
+}
+
+// ListDocuments returns a user's documents, newest first, without their
+// text, which list views do not need
+func (r *DocumentRepository) ListDocuments(ctx context.Context, userID string) ([]*models.Document, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT id::text, user_id, title, filename, content_type, file_size, sha256, page_count, created_at,
+		        (SELECT COUNT(*) FROM quizzes q WHERE q.document_id = documents.id)
+		 FROM documents
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	documents := []*models.Document{}
+	for rows.Next() {
+		doc := &models.Document{}
+		if err := rows.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Filename, &doc.ContentType, &doc.Size, &doc.Hash,
+			&doc.PageCount, &doc.CreatedAt, &doc.QuizCount); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		documents = append(documents, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read documents: %w", err)
+	}
+	return documents, nil
+}
+
+// GetFileData returns the original file of a document, nil for pasted text
+func (r *DocumentRepository) GetFileData(ctx context.Context, id string) ([]byte, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	var data []byte
+	err := db.QueryRow(ctx, `SELECT file_data FROM documents WHERE id = $1`, id).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document file: %w", err)
+	}
+	return data, nil
+}
+
+// DeleteDocument removes a document; its quizzes are kept without it
+func (r *DocumentRepository) DeleteDocument(ctx context.Context, id string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	tag, err := db.Exec(ctx, `DELETE FROM documents WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDocumentNotFound
+	}
+	return nil
+}
+
+// GetStudyNotes returns the stored study notes of a document, or nil when
+// none were generated yet
+func (r *DocumentRepository) GetStudyNotes(ctx context.Context, documentID string) (*models.StudyNotes, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	var notesJSON []byte
+	err := db.QueryRow(ctx, `SELECT study_notes FROM documents WHERE id = $1`, documentID).Scan(&notesJSON)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get study notes: %w", err)
+	}
+	if len(notesJSON) == 0 {
+		return nil, nil
+	}
+
+	var notes models.StudyNotes
+	if err := json.Unmarshal(notesJSON, &notes); err != nil {
+		return nil, fmt.Errorf("failed to parse study notes: %w", err)
+	}
+	return &notes, nil
+}
+
+// SaveStudyNotes stores study notes on their document
+func (r *DocumentRepository) SaveStudyNotes(ctx context.Context, notes *models.StudyNotes) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	notesJSON, err := json.Marshal(notes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal study notes: %w", err)
+	}
+	_, err = db.Exec(ctx, `UPDATE documents SET study_notes = $2::jsonb WHERE id = $1`, notes.DocumentID, string(notesJSON))
+	if err != nil {
+		return fmt.Errorf("failed to save study notes: %w", err)
+	}
+	return nil
+}
+
+// scanDocument reads a row selected with documentColumns
+func scanDocument(row pgx.Row) (*models.Document, error) {
+	doc := &models.Document{}
+	var pagesJSON, sectionsJSON []byte
+	err := row.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Filename, &doc.ContentType, &doc.Size, &doc.Hash,
+		&doc.Content, &pagesJSON, &sectionsJSON, &doc.PageCount, &doc.CreatedAt, &doc.QuizCount)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	if err := json.Unmarshal(pagesJSON, &doc.Pages); err != nil {
+		return nil, fmt.Errorf("failed to parse document pages: %w", err)
+	}
+	if err := json.Unmarshal(sectionsJSON, &doc.Sections); err != nil {
+		return nil, fmt.Errorf("failed to parse document sections: %w", err)
+	}
+	return doc, nil
+}
+
+// nonNilSlice returns s, or an empty slice when s is nil, so it is stored
+// as a JSON array rather than null
+func nonNilSlice[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
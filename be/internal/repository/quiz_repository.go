@@ -97,13 +97,37 @@ func (r *QuizRepository) CreateQuiz(ctx context.Context, quiz *models.Quiz, user
 	}
 	defer tx.Rollback(ctx)
 
+	// Quizzes generated before documents were tracked have no document ID
+	var documentID interface{}
+	if quiz.DocumentID != "" {
+		documentID = quiz.DocumentID
+	}
+
+	// Only practice quizzes are linked to a parent quiz and attempt
+	parentQuizID, err := nullableID(quiz.ParentQuizID)
+	if err != nil {
+		return fmt.Errorf("invalid parent quiz ID format: %w", err)
+	}
+	sourceAttemptID, err := nullableID(quiz.SourceAttemptID)
+	if err != nil {
+		return fmt.Errorf("invalid source attempt ID format: %w", err)
+	}
+
+	// Quizzes generated from a whole document have no page selection
+	var selectedPages interface{}
+	var selectedSections []string
+	if quiz.Selection != nil {
+		selectedPages = quiz.Selection.Pages
+		selectedSections = quiz.Selection.Sections
+	}
+
 	// Insert quiz with question_count initialized to 0 (trigger will auto-increment as questions are inserted)
 	var quizID int64
 	err = tx.QueryRow(ctx,
-		`INSERT INTO quizzes (user_id, title, description, difficulty, pdf_filename, question_count, created_at) 
-		 VALUES ($1, $2, $3, $4, $5, 0, $6) 
+		`INSERT INTO quizzes (user_id, title, description, difficulty, pdf_filename, document_id, parent_quiz_id, source_attempt_id, selected_pages, selected_sections, question_count, created_at) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 0, $11) 
 		 RETURNING id`,
-		userID, quiz.Title, quiz.Description, quiz.Difficulty, quiz.Title, time.Now(),
+		userID, quiz.Title, quiz.Description, quiz.Difficulty, quiz.Filename, documentID, parentQuizID, sourceAttemptID, selectedPages, selectedSections, time.Now(),
 	).Scan(&quizID)
 	if err != nil {
 		return fmt.Errorf("failed to insert quiz: %w", err)
@@ -162,13 +186,19 @@ func (r *QuizRepository) GetQuiz(ctx context.Context, id string) (*models.Quiz,
 
 	// Get quiz
 	var quiz models.Quiz
-	var title, description, difficulty, pdfFilename, userID string
+	var title, description, difficulty, pdfFilename, userID, documentID string
+	var parentQuizID, sourceAttemptID, selectedPages string
+	var selectedSections []string
 	var createdAt time.Time
 
 	err := db.QueryRow(ctx,
-		`SELECT id, user_id, title, description, difficulty, pdf_filename, created_at FROM quizzes WHERE id = $1`,
+		`SELECT id, user_id, title, description, difficulty, pdf_filename, COALESCE(document_id::text, ''),
+		        COALESCE(parent_quiz_id::text, ''), COALESCE(source_attempt_id::text, ''),
+		        COALESCE(selected_pages, ''), selected_sections, created_at
+		 FROM quizzes WHERE id = $1`,
 		id,
-	).Scan(&quiz.ID, &userID, &title, &description, &difficulty, &pdfFilename, &createdAt)
+	).Scan(&quiz.ID, &userID, &title, &description, &difficulty, &pdfFilename, &documentID, &parentQuizID, &sourceAttemptID,
+		&selectedPages, &selectedSections, &createdAt)
 	if err == pgx.ErrNoRows {
 		return nil, fmt.Errorf("quiz not found")
 	}
@@ -177,6 +207,13 @@ func (r *QuizRepository) GetQuiz(ctx context.Context, id string) (*models.Quiz,
 	}
 
 	quiz.UserID = userID
+	quiz.Filename = pdfFilename
+	quiz.DocumentID = documentID
+	quiz.ParentQuizID = parentQuizID
+	quiz.SourceAttemptID = sourceAttemptID
+	if selectedPages != "" {
+		quiz.Selection = &models.PageSelection{Pages: selectedPages, Sections: selectedSections}
+	}
 
 	quiz.Title = title
 	quiz.Description = description
@@ -242,7 +279,7 @@ func (r *QuizRepository) GetAllQuizzes(ctx context.Context, userID string) ([]*m
 	}
 
 	rows, err := db.Query(ctx,
-		`SELECT id, title, description, difficulty, pdf_filename, created_at, COALESCE(question_count, 0) as question_count
+		`SELECT id, title, description, difficulty, pdf_filename, COALESCE(document_id::text, ''), COALESCE(parent_quiz_id::text, ''), created_at, COALESCE(question_count, 0) as question_count
 		 FROM quizzes
 		 WHERE user_id = $1
 		 ORDER BY created_at DESC`,
@@ -260,13 +297,14 @@ func (r *QuizRepository) GetAllQuizzes(ctx context.Context, userID string) ([]*m
 		var createdAt time.Time
 		var questionCount int
 
-		if err := rows.Scan(&quiz.ID, &title, &description, &difficulty, &pdfFilename, &createdAt, &questionCount); err != nil {
+		if err := rows.Scan(&quiz.ID, &title, &description, &difficulty, &pdfFilename, &quiz.DocumentID, &quiz.ParentQuizID, &createdAt, &questionCount); err != nil {
 			return nil, fmt.Errorf("failed to scan quiz: %w", err)
 		}
 
 		quiz.Title = title
 		quiz.Description = description
 		quiz.Difficulty = difficulty
+		quiz.Filename = pdfFilename
 		quiz.CreatedAt = createdAt
 		quiz.UpdatedAt = createdAt
 		quiz.TotalQuestions = questionCount
@@ -315,6 +353,21 @@ func (r *QuizRepository) DeleteQuiz(ctx context.Context, id string) error {
 	return nil
 }
 
+// CountQuizzesForDocument returns how many quizzes were generated from a document
+func (r *QuizRepository) CountQuizzesForDocument(ctx context.Context, documentID string) (int, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database connection not initialized")
+	}
+
+	var count int
+	err := db.QueryRow(ctx, `SELECT COUNT(*) FROM quizzes WHERE document_id = $1`, documentID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count quizzes for document: %w", err)
+	}
+	return count, nil
+}
+
 // SaveQuizAttempt saves a quiz attempt to the database
 func (r *QuizRepository) SaveQuizAttempt(ctx context.Context, quizID string, userID string, score int, totalQuestions int, answers map[string]string) (string, error) {
 	db := database.GetDB()
@@ -348,6 +401,46 @@ func (r *QuizRepository) SaveQuizAttempt(ctx context.Context, quizID string, use
 	return fmt.Sprintf("%d", attemptID), nil
 }
 
+// GetAttempt retrieves the stored answers of a quiz attempt
+func (r *QuizRepository) GetAttempt(ctx context.Context, attemptID string) (*models.QuizAttempt, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	attemptIDInt, err := strconv.ParseInt(attemptID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attempt ID format: %w", err)
+	}
+
+	var quizID int64
+	var userAnswersJSON []byte
+	attempt := &models.QuizAttempt{ID: attemptID}
+
+	err = db.QueryRow(ctx,
+		`SELECT quiz_id, user_id, score, total_questions, user_answers, created_at 
+		 FROM quiz_attempts 
+		 WHERE id = $1`,
+		attemptIDInt,
+	).Scan(&quizID, &attempt.UserID, &attempt.Score, &attempt.TotalQuestions, &userAnswersJSON, &attempt.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("attempt not found")
+		}
+		return nil, fmt.Errorf("failed to get quiz attempt: %w", err)
+	}
+
+	attempt.QuizID = fmt.Sprintf("%d", quizID)
+	attempt.Answers = make(map[string]string)
+	if len(userAnswersJSON) > 0 {
+		if err := json.Unmarshal(userAnswersJSON, &attempt.Answers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal user answers: %w", err)
+		}
+	}
+
+	return attempt, nil
+}
+
 // GetQuizAttempt retrieves a quiz attempt by ID
 func (r *QuizRepository) GetQuizAttempt(ctx context.Context, attemptID string) (map[string]interface{}, error) {
 	db := database.GetDB()
@@ -515,3 +608,11 @@ func (r *QuizRepository) ListUserAttempts(ctx context.Context, userID string) ([
 
 	return attempts, nil
 }
+
+// nullableID converts an optional numeric ID into a value pgx stores as NULL when empty
+func nullableID(id string) (interface{}, error) {
+	if id == "" {
+		return nil, nil
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
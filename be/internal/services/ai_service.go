@@ -3,10 +3,12 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"pbkk-quizlit-backend/internal/models"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"bytes"
 	"io"
@@ -27,8 +29,67 @@ type AIService struct {
 	// RAG components
 	rag       *RAGService
 	enableRAG bool
+	// mmrLambda diversifies retrieved chunks; 0 disables MMR re-ranking
+	mmrLambda float64
+	// chatMinScore is the retrieval confidence below which document chat refuses to answer
+	chatMinScore float64
+	// enableStreaming reads Senopati completions as streams so questions are parsed as they arrive
+	enableStreaming bool
+	// scheduler bounds concurrent LLM calls across all users
+	scheduler *LLMScheduler
+	// quiz size limits and batched generation
+	questionBatchSize      int
+	maxQuestionCount       int
+	questionLimitOverrides map[string]int
 }
 
+// AIServiceOptions configures the AI service from the server config
+type AIServiceOptions struct {
+	EnableRAG bool
+	// EnableStreaming streams completions so questions can be shown live
+	EnableStreaming bool
+	// QuestionBatchSize is how many questions are requested per LLM call
+	QuestionBatchSize int
+	// MaxQuestionCount is the largest quiz a user may request
+	MaxQuestionCount int
+	// QuestionLimitOverrides raises or lowers MaxQuestionCount for specific users
+	QuestionLimitOverrides map[string]int
+	// Embedder replaces the default local embedding used for RAG
+	Embedder EmbeddingProvider
+	// VectorStore replaces the default in-memory store used for RAG
+	VectorStore VectorStore
+	// LexicalWeight is the share of BM25 keyword ranking in hybrid retrieval,
+	// from 0 (vector similarity only) to 1 (keywords only)
+	LexicalWeight float64
+	// MMRLambda trades relevance (1) for diversity (towards 0) when picking
+	// chunks for a prompt; 0 disables MMR re-ranking
+	MMRLambda float64
+	// ChatMinScore is the best chunk similarity a document chat question
+	// needs to be answered; below it the answer is a refusal
+	ChatMinScore float64
+	// MaxConcurrentLLMCalls and MaxQueuedLLMCalls configure the LLM scheduler
+	MaxConcurrentLLMCalls int
+	MaxQueuedLLMCalls     int
+	// Senopati configures retries and the circuit breaker of Senopati calls
+	Senopati SenopatiOptions
+}
+
+const (
+	// defaultQuestionBatchSize questions are requested per LLM call
+	defaultQuestionBatchSize = 10
+	// defaultMaxQuestionCount is the quiz size limit when none is configured
+	defaultMaxQuestionCount = 100
+	// MinQuestionCount is the smallest quiz that may be requested
+	MinQuestionCount = 5
+	// tokensPerQuestion is the completion budget reserved for each question
+	tokensPerQuestion = 400
+	// minCompletionTokens and maxCompletionTokens bound a single LLM call
+	minCompletionTokens = 4000
+	maxCompletionTokens = 8000
+	// maxSenopatiContentLength keeps prompts small enough for good performance
+	maxSenopatiContentLength = 10000
+)
+
 func NewAIService(apiKey string) *AIService {
 	var client *openai.Client
 	useOpenAI := false
@@ -52,23 +113,150 @@ func NewAIService(apiKey string) *AIService {
 		apiKey:         apiKey,
 		useOpenAI:      useOpenAI,
 		useSenopati:    useSenopati,
+
+		scheduler:         NewLLMScheduler(defaultLLMMaxInFlight, defaultLLMMaxQueue),
+		questionBatchSize: defaultQuestionBatchSize,
+		maxQuestionCount:  defaultMaxQuestionCount,
 	}
-	// initialize lightweight RAG with hash embedding (works without external deps)
-	ai.rag = NewRAGService(HashEmbedding{})
+	// initialize lightweight RAG with a local lexical embedding (works without external deps)
+	ai.rag = NewRAGService(NewLocalEmbedding(defaultLocalEmbeddingDims))
 	// Enable RAG to intelligently select relevant content chunks
 	ai.enableRAG = true
+	ai.mmrLambda = defaultMMRLambda
+	ai.chatMinScore = defaultChatMinScore
 	return ai
 }
 
-// NewAIServiceWithOptions allows configuring RAG usage via flag
-func NewAIServiceWithOptions(apiKey string, enableRAG bool) *AIService {
+// NewAIServiceWithOptions allows configuring RAG usage and quiz size limits
+func NewAIServiceWithOptions(apiKey string, opts AIServiceOptions) *AIService {
 	ai := NewAIService(apiKey)
-	ai.enableRAG = enableRAG
+	ai.enableRAG = opts.EnableRAG
+	ai.enableStreaming = opts.EnableStreaming
+	if opts.Embedder != nil || opts.VectorStore != nil {
+		embedder := opts.Embedder
+		if embedder == nil {
+			embedder = NewLocalEmbedding(defaultLocalEmbeddingDims)
+		}
+		store := opts.VectorStore
+		if store == nil {
+			store = NewMemoryVectorStore()
+		}
+		ai.rag = NewRAGServiceWithStore(embedder, store)
+	}
+	ai.rag.SetLexicalWeight(opts.LexicalWeight)
+	ai.mmrLambda = opts.MMRLambda
+	ai.chatMinScore = opts.ChatMinScore
+	if opts.QuestionBatchSize > 0 {
+		// A batch must fit in a single completion budget
+		ai.questionBatchSize = min(opts.QuestionBatchSize, maxCompletionTokens/tokensPerQuestion)
+	}
+	if opts.MaxQuestionCount >= MinQuestionCount {
+		ai.maxQuestionCount = opts.MaxQuestionCount
+	} else if opts.MaxQuestionCount > 0 {
+		ai.logger.Warnf("Ignoring question limit %d below the minimum quiz size of %d", opts.MaxQuestionCount, MinQuestionCount)
+	}
+	// A limit below the minimum quiz size would reject every quiz the user
+	// asks for, so it is raised to the minimum
+	ai.questionLimitOverrides = make(map[string]int, len(opts.QuestionLimitOverrides))
+	for userID, limit := range opts.QuestionLimitOverrides {
+		if limit < MinQuestionCount {
+			ai.logger.Warnf("Raising question limit %d of user %s to the minimum quiz size of %d", limit, userID, MinQuestionCount)
+			limit = MinQuestionCount
+		}
+		ai.questionLimitOverrides[userID] = limit
+	}
+	ai.scheduler = NewLLMScheduler(opts.MaxConcurrentLLMCalls, opts.MaxQueuedLLMCalls)
+	ai.senopatiClient = NewSenopatiClientWithOptions(opts.Senopati)
 	return ai
 }
 
+// SchedulerStats reports the LLM queue state for monitoring
+func (ai *AIService) SchedulerStats() LLMSchedulerStats {
+	return ai.scheduler.Stats()
+}
+
+// EvictDocument drops a document's chunks from the retrieval index
+func (ai *AIService) EvictDocument(ctx context.Context, docID string) {
+	if ai.rag == nil {
+		return
+	}
+	if err := ai.rag.DeleteDocument(ctx, docID); err != nil {
+		ai.logger.Warnf("Failed to evict document %s from RAG index: %v", docID, err)
+	}
+}
+
+// SenopatiHealth reports the Senopati circuit breaker state for monitoring
+func (ai *AIService) SenopatiHealth() CircuitHealth {
+	return ai.senopatiClient.Health()
+}
+
+// generateText calls the Senopati /generate endpoint once an LLM slot is free for userID
+func (ai *AIService) generateText(ctx context.Context, userID, model, prompt string, temperature float64, maxTokens int) (*GenerateResponse, error) {
+	var resp *GenerateResponse
+	err := ai.scheduler.Do(ctx, userID, func() error {
+		var err error
+		resp, err = ai.senopatiClient.GenerateText(ctx, model, prompt, temperature, maxTokens)
+		return err
+	})
+	return resp, err
+}
+
+// streamQuestions streams a completion once an LLM slot is free for userID
+// and passes each complete question to accept as soon as it has been
+// parsed. It stops reading early once accept reports the batch is full.
+func (ai *AIService) streamQuestions(ctx context.Context, userID, model, prompt string, temperature float64, maxTokens int, accept func(models.Question) bool) error {
+	return ai.scheduler.Do(ctx, userID, func() error {
+		stream, err := ai.senopatiClient.GenerateTextStream(ctx, model, prompt, temperature, maxTokens)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		var parser questionStreamParser
+		defer func() {
+			if parser.skipped > 0 {
+				ai.logger.Warnf("Skipped %d malformed questions in stream", parser.skipped)
+			}
+		}()
+
+		for chunk := range stream.Chunks {
+			if chunk.Err != nil {
+				return chunk.Err
+			}
+			for _, q := range parser.Feed(chunk.Text) {
+				if !accept(q) {
+					return nil
+				}
+			}
+			if chunk.Done {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// MaxQuestionsForUser returns the largest quiz the user may request
+func (ai *AIService) MaxQuestionsForUser(userID string) int {
+	if limit, ok := ai.questionLimitOverrides[userID]; ok {
+		return limit
+	}
+	return ai.maxQuestionCount
+}
+
 // GenerateQuizFromContent generates quiz questions using AI or free alternatives
-func (ai *AIService) GenerateQuizFromContent(content string, req *models.QuizGenerationRequest) (*models.Quiz, error) {
+func (ai *AIService) GenerateQuizFromContent(ctx context.Context, content string, req *models.QuizGenerationRequest) (*models.Quiz, error) {
+	return ai.generateQuiz(ctx, content, req, nil)
+}
+
+// GenerateQuizFromContentStream is like GenerateQuizFromContent but calls
+// onQuestion with every accepted question as soon as it is generated, so
+// clients can show progress before the whole quiz is done
+func (ai *AIService) GenerateQuizFromContentStream(ctx context.Context, content string, req *models.QuizGenerationRequest, onQuestion func(models.Question)) (*models.Quiz, error) {
+	return ai.generateQuiz(ctx, content, req, onQuestion)
+}
+
+func (ai *AIService) generateQuiz(ctx context.Context, content string, req *models.QuizGenerationRequest, onQuestion func(models.Question)) (*models.Quiz, error) {
 	if req.QuestionCount == 0 {
 		req.QuestionCount = 10 // Default to 10 questions
 	}
@@ -78,54 +266,36 @@ func (ai *AIService) GenerateQuizFromContent(content string, req *models.QuizGen
 	var questions []models.Question
 	var err error
 
+	// Large quizzes are generated in batches, each grounded in its own slice of the material
+	batches := ai.batchCount(req.QuestionCount)
+
 	// Build RAG index and retrieve top context chunks to ground prompts
+	var contexts []string
 	if ai.rag != nil && ai.enableRAG {
-		ai.logger.Info("Using RAG to select relevant content chunks")
-		// Use title or a generated docID
-		docID := req.Title
-		if strings.TrimSpace(docID) == "" {
+		// Index under the source document; content without one gets a
+		// throwaway ID whose chunks are evicted once the quiz is generated
+		docID := req.DocumentID
+		if docID == "" {
 			docID = uuid.New().String()
+			defer ai.EvictDocument(context.WithoutCancel(ctx), docID)
 		}
-		if err := ai.rag.BuildIndex(docID, content); err == nil {
-			// retrieve with query from description+difficulty for better intent
-			query := strings.TrimSpace(req.Description + " " + req.Difficulty)
-			if query == "" {
-				query = "generate quiz key concepts"
-			}
-			top, _ := ai.rag.Retrieve(query, 8) // Get more chunks for better coverage
-			if len(top) > 0 {
-				// Assemble clean context without metadata prefixes
-				var b strings.Builder
-				totalLength := 0
-				maxTotalLength := 8000 // Keep under 8KB for better performance
-
-				for i, it := range top {
-					chunkText := strings.TrimSpace(it.Text)
-
-					// Skip if would exceed limit
-					if totalLength+len(chunkText) > maxTotalLength {
-						break
-					}
-
-					if i > 0 {
-						b.WriteString("\n\n")
-					}
-					b.WriteString(chunkText)
-					totalLength += len(chunkText) + 2
-				}
-
-				// Replace content with curated chunks
-				content = b.String()
-				ai.logger.Infof("RAG selected %d chunks, total length: %d chars", len(top), len(content))
-			}
-		} else {
-			ai.logger.Warnf("RAG indexing failed: %v", err)
+		// retrieve with query from description+difficulty for better intent
+		query := strings.TrimSpace(req.Description + " " + req.Difficulty)
+		if query == "" {
+			query = "generate quiz key concepts"
+		}
+		pages := req.Pages
+		if len(pages) == 0 {
+			pages = contentPages(content)
 		}
+		contexts = ai.buildRAGContexts(ctx, req.UserID, docID, content, pages, query, batches)
+	} else {
+		contexts = splitContent(content, batches, maxSenopatiContentLength)
 	}
 
 	// Try Senopati first (ITS local LLM)
 	if ai.useSenopati {
-		questions, err = ai.generateWithSenopati(content, req)
+		questions, err = ai.generateWithSenopati(ctx, contexts, req, onQuestion)
 		if err != nil {
 			ai.logger.Errorf("Senopati failed: %v", err)
 			return nil, fmt.Errorf("quiz generation failed: %w", err)
@@ -150,13 +320,150 @@ func (ai *AIService) GenerateQuizFromContent(content string, req *models.QuizGen
 	return quiz, nil
 }
 
+// buildRAGContext indexes pages under the user's docID unless they already
+// are, and returns the chunks of that document most relevant to query,
+// joined into a prompt-sized context. The original content is returned unchanged when indexing or
+// retrieval yields nothing.
+func (ai *AIService) buildRAGContext(ctx context.Context, userID, docID, content string, pages []models.DocumentPage, query string) string {
+	return ai.buildRAGContexts(ctx, userID, docID, content, pages, query, 1)[0]
+}
+
+// buildRAGContexts is like buildRAGContext but retrieves enough chunks for
+// parts separate prompts, spreading the most relevant chunks across them so
+// every batch of a large quiz sees different material.
+func (ai *AIService) buildRAGContexts(ctx context.Context, userID, docID, content string, pages []models.DocumentPage, query string, parts int) []string {
+	if parts < 1 {
+		parts = 1
+	}
+
+	ai.logger.Info("Using RAG to select relevant content chunks")
+	// A stored document keeps its chunks between generations; re-indexing it
+	// would briefly leave its chats without chunks
+	if err := ai.rag.EnsureIndexed(ctx, userID, docID, pages); err != nil {
+		ai.logger.Warnf("RAG indexing failed: %v", err)
+		return splitContent(content, parts, maxSenopatiContentLength)
+	}
+
+	top, _ := ai.rag.Retrieve(ctx, query, 8*parts, RetrieveOptions{ // Get more chunks for better coverage
+		Filter:    VectorFilter{UserID: userID, DocumentIDs: []string{docID}},
+		MMRLambda: ai.mmrLambda,
+	})
+	if len(top) == 0 {
+		return splitContent(content, parts, maxSenopatiContentLength)
+	}
+
+	// Deal chunks round-robin so each part mixes highly and less relevant chunks
+	builders := make([]strings.Builder, parts)
+	maxTotalLength := 8000 // Keep under 8KB for better performance
+
+	for i, it := range top {
+		// Assemble clean context without metadata prefixes
+		chunkText := strings.TrimSpace(it.Text)
+		b := &builders[i%parts]
+
+		// Skip if would exceed limit
+		if b.Len()+len(chunkText)+2 > maxTotalLength {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(chunkText)
+	}
+
+	contexts := make([]string, 0, parts)
+	for i := range builders {
+		if builders[i].Len() > 0 {
+			contexts = append(contexts, builders[i].String())
+		}
+	}
+
+	ai.logger.Infof("RAG selected %d chunks across %d contexts", len(top), len(contexts))
+	return contexts
+}
+
+// splitContent cuts content into at most parts consecutive windows of maxLength bytes
+func splitContent(content string, parts, maxLength int) []string {
+	if parts <= 1 || len(content) <= maxLength {
+		return []string{content}
+	}
+
+	window := len(content) / parts
+	if window > maxLength {
+		window = maxLength
+	}
+
+	var out []string
+	for start := 0; start < len(content) && len(out) < parts; {
+		end := min(start+window, len(content))
+		// Don't split inside a UTF-8 sequence
+		for end < len(content) && !utf8.RuneStart(content[end]) {
+			end++
+		}
+		out = append(out, content[start:end])
+		start = end
+	}
+	return out
+}
+
+// batchCount returns how many LLM calls are needed for count questions
+func (ai *AIService) batchCount(count int) int {
+	if count <= ai.questionBatchSize {
+		return 1
+	}
+	return (count + ai.questionBatchSize - 1) / ai.questionBatchSize
+}
+
+// completionTokenBudget scales max tokens with the number of questions requested
+func completionTokenBudget(questionCount int) int {
+	maxTokens := questionCount * tokensPerQuestion
+	if maxTokens < minCompletionTokens {
+		maxTokens = minCompletionTokens // Minimum for safety
+	}
+	if maxTokens > maxCompletionTokens {
+		maxTokens = maxCompletionTokens
+	}
+	return maxTokens
+}
+
+// selectSenopatiModel picks the best available Senopati model
+func (ai *AIService) selectSenopatiModel(ctx context.Context) string {
+	// Get available models from API
+	modelsResp, err := ai.senopatiClient.ListModels(ctx)
+	if err != nil || len(modelsResp.Models) == 0 {
+		// Fallback to default model from docs
+		model := "qwen2.5:14b"
+		ai.logger.Warnf("Could not fetch models (error: %v), using default: %s", err, model)
+		return model
+	}
+
+	// Prefer larger models for better quality and completion
+	// Priority: qwen2.5:14b > llama3:latest > qwen2.5:7b > gemma:7b
+	preferredModels := []string{"qwen2.5:14b", "llama3:latest", "qwen2.5:7b", "llama3", "qwen2.5"}
+
+	for _, preferred := range preferredModels {
+		for _, available := range modelsResp.Models {
+			if strings.Contains(available, preferred) {
+				ai.logger.Infof("Using Senopati model: %s (selected from %d available models)", available, len(modelsResp.Models))
+				return available
+			}
+		}
+	}
+
+	// If no preferred model found, use first available
+	model := modelsResp.Models[0]
+	ai.logger.Infof("Using Senopati model: %s (default from %d available models)", model, len(modelsResp.Models))
+	return model
+}
+
 // generateWithOpenAI uses OpenAI GPT for quiz generation
-func (ai *AIService) generateWithOpenAI(content string, req models.CreateQuizRequest) ([]models.Question, error) {
+func (ai *AIService) generateWithOpenAI(ctx context.Context, content string, req models.CreateQuizRequest) ([]models.Question, error) {
 	prompt := ai.createPrompt(content, req)
 
 	// Call OpenAI API
 	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
 			Model: openai.GPT3Dot5Turbo,
 			Messages: []openai.ChatCompletionMessage{
@@ -192,79 +499,133 @@ func (ai *AIService) generateWithOpenAI(content string, req models.CreateQuizReq
 	return questions, nil
 }
 
-// generateWithSenopati uses ITS Senopati local LLM for quiz generation
-func (ai *AIService) generateWithSenopati(content string, req *models.QuizGenerationRequest) ([]models.Question, error) {
+// generateWithSenopati uses ITS Senopati local LLM for quiz generation.
+// Quizzes larger than one batch are requested batch by batch; each batch is
+// told which questions already exist and near-duplicates are dropped, with
+// a couple of extra rounds to make up for questions lost that way.
+// onQuestion, if set, is called for each question as it is accepted.
+func (ai *AIService) generateWithSenopati(ctx context.Context, contexts []string, req *models.QuizGenerationRequest, onQuestion func(models.Question)) ([]models.Question, error) {
 	ai.logger.Info("Using ITS Senopati LLM for quiz generation")
+	if len(contexts) == 0 {
+		return nil, fmt.Errorf("no content to generate questions from")
+	}
 
-	// Truncate content if too large (max ~10KB for better performance)
-	maxContentLength := 10000
-	if len(content) > maxContentLength {
-		ai.logger.Warnf("Content too large (%d chars), truncating to %d", len(content), maxContentLength)
-		content = content[:maxContentLength] + "\\n[Content truncated due to size...]"
+	model := ai.selectSenopatiModel(ctx)
+
+	batches := ai.batchCount(req.QuestionCount)
+	maxRounds := batches
+	if batches > 1 {
+		maxRounds += 2
 	}
 
-	prompt := ai.buildPrompt(content, req)
+	var questions []models.Question
+	var lastErr error
 
-	// Get available models from API
-	modelsResp, err := ai.senopatiClient.ListModels()
-	var model string
-	if err == nil && len(modelsResp.Models) > 0 {
-		// Prefer larger models for better quality and completion
-		// Priority: qwen2.5:14b > llama3:latest > qwen2.5:7b > gemma:7b
-		preferredModels := []string{"qwen2.5:14b", "llama3:latest", "qwen2.5:7b", "llama3", "qwen2.5"}
-
-		for _, preferred := range preferredModels {
-			for _, available := range modelsResp.Models {
-				if strings.Contains(available, preferred) {
-					model = available
-					ai.logger.Infof("Using Senopati model: %s (selected from %d available models)", model, len(modelsResp.Models))
-					goto modelSelected
-				}
+	for round := 0; round < maxRounds && len(questions) < req.QuestionCount; round++ {
+		// Nobody is waiting for the result any more
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		need := min(ai.questionBatchSize, req.QuestionCount-len(questions))
+
+		// Truncate content if too large (max ~10KB for better performance)
+		content := contexts[round%len(contexts)]
+		if len(content) > maxSenopatiContentLength {
+			ai.logger.Warnf("Content too large (%d chars), truncating to %d", len(content), maxSenopatiContentLength)
+			content = content[:maxSenopatiContentLength] + "\\n[Content truncated due to size...]"
+		}
+
+		prompt := ai.buildPrompt(content, req, need, questions)
+
+		if batches > 1 {
+			ai.logger.Infof("Requesting batch %d (%d questions, %d/%d generated so far)", round+1, need, len(questions), req.QuestionCount)
+		}
+
+		added := 0
+		// accept adds q unless it repeats an earlier question and reports
+		// whether the batch wants more
+		accept := func(q models.Question) bool {
+			if added >= need {
+				return false
+			}
+			if isNearDuplicateQuestion(q.Text, questions) {
+				ai.logger.Warnf("Dropping duplicate question: %s", q.Text)
+				return true
 			}
+			questions = append(questions, q)
+			added++
+			if onQuestion != nil {
+				onQuestion(q)
+			}
+			return added < need
 		}
 
-		// If no preferred model found, use first available
-		model = modelsResp.Models[0]
-		ai.logger.Infof("Using Senopati model: %s (default from %d available models)", model, len(modelsResp.Models))
-	modelSelected:
-	} else {
-		// Fallback to default model from docs
-		model = "qwen2.5:14b"
-		ai.logger.Warnf("Could not fetch models (error: %v), using default: %s", err, model)
+		// Call Senopati Generate endpoint
+		// Scale max tokens based on question count (each question ~300 tokens)
+		var err error
+		if ai.enableStreaming {
+			err = ai.streamQuestions(ctx, req.UserID, model, prompt, 0.7, completionTokenBudget(need), accept)
+		} else {
+			var resp *GenerateResponse
+			resp, err = ai.generateText(ctx, req.UserID, model, prompt, 0.7, completionTokenBudget(need))
+			if err == nil {
+				// Parse the response using the same parser
+				var parsed []models.Question
+				parsed, err = ai.parseAIResponse(resp.Response)
+				if err != nil {
+					lastErr = err
+					ai.logger.Errorf("Failed to parse Senopati response: %v", err)
+					continue
+				}
+				for _, q := range parsed {
+					if !accept(q) {
+						break
+					}
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, ErrLLMQueueFull) || errors.Is(err, ErrCircuitOpen) {
+			if len(questions) == 0 {
+				return nil, err
+			}
+			// Keep what we have rather than failing a mostly generated quiz
+			ai.logger.Warnf("LLM unavailable during batch %d, stopping early: %v", round+1, err)
+			break
+		}
+		if err != nil {
+			// Questions streamed before the failure are kept
+			lastErr = fmt.Errorf("senopati API error: %w", err)
+			ai.logger.Errorf("Batch %d failed after %d questions: %v", round+1, added, lastErr)
+			continue
+		}
 	}
 
-	// Call Senopati Generate endpoint
-	// Scale max tokens based on question count (each question ~300 tokens)
-	maxTokens := req.QuestionCount * 400
-	if maxTokens < 4000 {
-		maxTokens = 4000 // Minimum for safety
-	}
-	if maxTokens > 8000 {
-		maxTokens = 8000 // Cap at 8000
-	}
-	resp, err := ai.senopatiClient.GenerateText(model, prompt, 0.7, maxTokens)
-	if err != nil {
-		return nil, fmt.Errorf("senopati API error: %w", err)
+	if len(questions) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no valid questions found in response")
+		}
+		return nil, lastErr
 	}
 
-	// Parse the response using the same parser
-	questions, err := ai.parseAIResponse(resp.Response)
-	if err != nil {
-		ai.logger.Errorf("Failed to parse Senopati response: %v", err)
-		return nil, err
+	if len(questions) < req.QuestionCount {
+		ai.logger.Warnf("Generated %d of %d requested questions", len(questions), req.QuestionCount)
 	}
 
 	return questions, nil
 }
 
 // generateWithOllama uses Ollama API for free local AI processing
-func (ai *AIService) generateWithOllama(content string, req *models.QuizGenerationRequest) ([]models.Question, error) {
+func (ai *AIService) generateWithOllama(ctx context.Context, content string, req *models.QuizGenerationRequest) ([]models.Question, error) {
 	ai.logger.Info("Using Ollama for quiz generation")
 
 	// Ollama API endpoint (default local installation)
 	url := "http://localhost:11434/api/generate"
 
-	prompt := ai.buildPrompt(content, req)
+	prompt := ai.buildPrompt(content, req, req.QuestionCount, nil)
 
 	requestBody := map[string]interface{}{
 		"model":  "llama2", // Default model, can be made configurable
@@ -277,7 +638,13 @@ func (ai *AIService) generateWithOllama(content string, req *models.QuizGenerati
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		ai.logger.Errorf("Ollama API request failed: %v", err)
 		return nil, fmt.Errorf("ollama API error: %w", err)
@@ -826,7 +1193,8 @@ func (ai *AIService) generateFillInTheBlank(sentence string, keywords []string)
 	}
 }
 
-func (ai *AIService) buildPrompt(content string, req *models.QuizGenerationRequest) string {
+// buildPrompt asks for count questions; questions listed in existing must not be repeated
+func (ai *AIService) buildPrompt(content string, req *models.QuizGenerationRequest, count int, existing []models.Question) string {
 	prompt := fmt.Sprintf(`Create a quiz with EXACTLY %d questions based on the following content. 
 
 Content:
@@ -873,11 +1241,39 @@ CRITICAL REQUIREMENTS:
 - Return ONLY a JSON array, no additional text or wrapper object
 
 Return ONLY valid JSON array, no markdown formatting.`,
-		req.QuestionCount, content, req.Title, req.Description, req.QuestionCount, req.QuestionCount, req.QuestionCount)
+		count, content, req.Title, req.Description, count, count, count)
+
+	if len(existing) > 0 {
+		prompt += "\n\nThese questions already exist in the quiz. Do NOT repeat or rephrase them; cover other facts and concepts:\n" +
+			existingQuestionList(existing, 3000)
+	}
 
 	return prompt
 }
 
+// existingQuestionList lists the most recent question texts within maxLength bytes
+func existingQuestionList(questions []models.Question, maxLength int) string {
+	var lines []string
+	total := 0
+	for i := len(questions) - 1; i >= 0; i-- {
+		text := questions[i].Text
+		if len(text) > 120 {
+			text = text[:120] + "..."
+		}
+		line := "- " + text
+		if total+len(line)+1 > maxLength {
+			break
+		}
+		lines = append(lines, line)
+		total += len(line) + 1
+	}
+	// Restore generation order
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (ai *AIService) createPrompt(content string, req models.CreateQuizRequest) string {
 	// Truncate content if too long
 	maxContentLength := 3000
@@ -959,12 +1355,7 @@ func (ai *AIService) parseAIResponse(response string) ([]models.Question, error)
 	// Log the cleaned response for debugging
 	ai.logger.Infof("Parsing AI response (first 500 chars): %s", response[:min(500, len(response))])
 
-	var rawQuestions []struct {
-		Question      string   `json:"question"`
-		Options       []string `json:"options"`
-		CorrectAnswer int      `json:"correctAnswer"`
-		Explanation   string   `json:"explanation"`
-	}
+	var rawQuestions []rawQuestion
 
 	if err := json.Unmarshal([]byte(response), &rawQuestions); err != nil {
 		ai.logger.Errorf("JSON parse error: %v\nResponse was: %s", err, response[:min(1000, len(response))])
@@ -975,24 +1366,12 @@ func (ai *AIService) parseAIResponse(response string) ([]models.Question, error)
 
 	var questions []models.Question
 	for i, rq := range rawQuestions {
-		if len(rq.Options) != 4 {
+		q, ok := rq.toQuestion()
+		if !ok {
 			ai.logger.Warnf("Skipping question %d with %d options: %s", i+1, len(rq.Options), rq.Question)
 			continue // Skip malformed questions
 		}
-
-		questions = append(questions, models.Question{
-			ID:            uuid.New().String(),
-			Text:          rq.Question, // Use Text field for database
-			Question:      rq.Question, // Keep Question for backward compatibility
-			Options:       rq.Options,
-			CorrectAnswer: rq.CorrectAnswer,
-			Explanation:   rq.Explanation,
-		})
-
-		// Limit to prevent excessive questions (max 15 to match user input)
-		if len(questions) >= 15 {
-			break
-		}
+		questions = append(questions, q)
 	}
 
 	if len(questions) == 0 {
@@ -1004,6 +1383,45 @@ func (ai *AIService) parseAIResponse(response string) ([]models.Question, error)
 	return questions, nil
 }
 
+// isNearDuplicateQuestion reports whether text repeats any of the given questions
+func isNearDuplicateQuestion(text string, others []models.Question) bool {
+	words := questionWordSet(text)
+	for _, other := range others {
+		if jaccard(words, questionWordSet(other.Text)) >= 0.8 {
+			return true
+		}
+	}
+	return false
+}
+
+func questionWordSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		w = strings.Trim(w, ".,!?;:()[]{}\"'")
+		if w != "" {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
 func (ai *AIService) generateFallbackQuestions(content string, req models.CreateQuizRequest) []models.Question {
 	ai.logger.Warn("Using fallback question generation")
 
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"pbkk-quizlit-backend/internal/models"
+	"pbkk-quizlit-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ChatService persists "ask your document" conversations
+type ChatService struct {
+	repo *repository.ChatRepository
+}
+
+func NewChatService() *ChatService {
+	return &ChatService{
+		repo: repository.NewChatRepository(),
+	}
+}
+
+// History returns the latest limit messages between a user and a document,
+// oldest first; 0 returns them all
+func (cs *ChatService) History(ctx context.Context, documentID, userID string, limit int) ([]models.DocumentChatMessage, error) {
+	return cs.repo.ListMessages(ctx, documentID, userID, limit)
+}
+
+// SaveExchange stores a question together with its answer, assigning IDs to
+// messages that have none
+func (cs *ChatService) SaveExchange(ctx context.Context, question, answer *models.DocumentChatMessage) error {
+	for _, msg := range []*models.DocumentChatMessage{question, answer} {
+		if msg.ID == "" {
+			msg.ID = uuid.New().String()
+		}
+	}
+	return cs.repo.SaveMessages(ctx, question, answer)
+}
+
+// DeleteDocument removes all conversations about a document
+func (cs *ChatService) DeleteDocument(ctx context.Context, documentID string) error {
+	_, err := cs.repo.DeleteDocumentMessages(ctx, documentID)
+	return err
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"pbkk-quizlit-backend/internal/extract"
+	"pbkk-quizlit-backend/internal/models"
+)
+
+// textChunk is a retrieval-sized piece of a document and where it came from
+type textChunk struct {
+	Text    string
+	Page    int
+	Section string
+}
+
+// contentPages wraps unstructured content as a single page without a number
+func contentPages(content string) []models.DocumentPage {
+	return []models.DocumentPage{{Number: 0, Text: content}}
+}
+
+// documentPages returns the pages of doc, falling back to its flat content
+func documentPages(doc *models.Document) []models.DocumentPage {
+	if len(doc.Pages) > 0 {
+		return doc.Pages
+	}
+	return contentPages(doc.Content)
+}
+
+// chunkPages splits pages into chunks of at most size runes. Chunks never
+// cross a page or a detected heading; within a section, paragraphs and then
+// sentences are packed together, and only text longer than size is split
+// at word boundaries. Consecutive chunks of the same section share about
+// overlap runes of trailing text.
+func chunkPages(pages []models.DocumentPage, size, overlap int) []textChunk {
+	if size <= 0 {
+		size = 800
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []textChunk
+	section := ""
+
+	for _, page := range pages {
+		var buf strings.Builder
+		bufLen := 0
+		// hasContent is false while buf holds only carried-over overlap
+		hasContent := false
+
+		flush := func(carry bool) {
+			text := strings.TrimSpace(buf.String())
+			if hasContent && text != "" {
+				chunks = append(chunks, textChunk{Text: text, Page: page.Number, Section: section})
+			}
+			buf.Reset()
+			bufLen = 0
+			hasContent = false
+			if carry && overlap > 0 && text != "" {
+				tail := tailRunes(text, overlap)
+				buf.WriteString(tail)
+				bufLen = utf8.RuneCountInString(tail)
+			}
+		}
+
+		add := func(piece string, newParagraph bool) {
+			sep := " "
+			if newParagraph {
+				sep = "\n\n"
+			}
+			n := utf8.RuneCountInString(piece)
+			if bufLen > 0 && bufLen+len(sep)+n > size {
+				flush(true)
+				// Drop the overlap rather than overflow the chunk
+				if bufLen+len(sep)+n > size {
+					buf.Reset()
+					bufLen = 0
+				}
+			}
+			if bufLen > 0 {
+				buf.WriteString(sep)
+				bufLen += len(sep)
+			}
+			buf.WriteString(piece)
+			bufLen += n
+			hasContent = true
+		}
+
+		for _, block := range pageBlocks(page.Text) {
+			if block.heading {
+				flush(false)
+				section = block.text
+			}
+			pieces := splitToFit(block.text, size)
+			if block.table {
+				pieces = splitTable(block.text, size)
+			}
+			for i, piece := range pieces {
+				add(piece, i == 0)
+			}
+		}
+		flush(false)
+	}
+	return chunks
+}
+
+// textBlock is a paragraph, a heading line or a Markdown table of a page
+type textBlock struct {
+	text    string
+	heading bool
+	table   bool
+}
+
+// pageBlocks groups the lines of a page into paragraphs, headings and
+// tables. Blank lines and list markers end a paragraph; wrapped lines are
+// joined, while the rows of a Markdown table stay on their own lines. A
+// fenced code block is one paragraph, so its lines are never headings.
+func pageBlocks(text string) []textBlock {
+	var blocks []textBlock
+	var para, rows []string
+	fence := ""
+
+	endParagraph := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, textBlock{text: strings.Join(para, " ")})
+			para = nil
+		}
+		if len(rows) > 0 {
+			blocks = append(blocks, textBlock{text: strings.Join(rows, "\n"), table: true})
+			rows = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if marker := extract.CodeFence(line); marker != "" && (fence == "" || marker == fence) {
+			endParagraph()
+			if fence == "" {
+				fence = marker
+			} else {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			if line != "" {
+				para = append(para, line)
+			}
+			continue
+		}
+		if line == "" {
+			endParagraph()
+			continue
+		}
+		if strings.HasPrefix(line, "|") {
+			if len(para) > 0 {
+				endParagraph()
+			}
+			rows = append(rows, line)
+			continue
+		}
+		if len(rows) > 0 {
+			endParagraph()
+		}
+		if heading, ok := headingText(line); ok {
+			endParagraph()
+			blocks = append(blocks, textBlock{text: heading, heading: true})
+			continue
+		}
+		if listItemPattern.MatchString(line) {
+			endParagraph()
+		}
+		para = append(para, line)
+	}
+	endParagraph()
+	return blocks
+}
+
+var (
+	// numberedHeadingPattern matches "2.1 Methods", "IV. Results" or "Bab 3 ..."
+	numberedHeadingPattern = regexp.MustCompile(`^(?:\d+(?:\.\d+)*\.?|[IVXLC]+\.)\s+\p{Lu}|^(?i:bab|chapter|section|bagian|part)\s+[\dIVXLC]+\b`)
+	listItemPattern        = regexp.MustCompile(`^(?:[•▪◦\-*]|\d+[.)]|[a-z][.)])\s`)
+)
+
+// headingText reports whether line looks like a section heading and returns
+// its text. Markdown headings, numbered headings and short all-caps lines
+// count; anything ending like a sentence does not.
+func headingText(line string) (string, bool) {
+	if strings.HasPrefix(line, "#") {
+		text := strings.TrimSpace(strings.TrimLeft(line, "#"))
+		return text, text != ""
+	}
+
+	n := utf8.RuneCountInString(line)
+	if n < 3 || n > 80 {
+		return "", false
+	}
+	last, _ := utf8.DecodeLastRuneInString(line)
+	if strings.ContainsRune(".,;:?!", last) {
+		return "", false
+	}
+	words := strings.Fields(line)
+	if len(words) > 12 {
+		return "", false
+	}
+	if numberedHeadingPattern.MatchString(line) {
+		return line, true
+	}
+
+	letters, upper := 0, 0
+	for _, r := range line {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	// A lone short acronym is more likely a stray word than a heading
+	if letters >= 4 && upper == letters && (len(words) > 1 || letters >= 6) {
+		return line, true
+	}
+	return "", false
+}
+
+// splitToFit returns text as pieces of at most size runes, preferring
+// sentence boundaries and falling back to words
+func splitToFit(text string, size int) []string {
+	if utf8.RuneCountInString(text) <= size {
+		return []string{text}
+	}
+	var pieces []string
+	for _, sentence := range splitSentences(text) {
+		if utf8.RuneCountInString(sentence) <= size {
+			pieces = append(pieces, sentence)
+			continue
+		}
+		pieces = append(pieces, splitWords(sentence, size)...)
+	}
+	return pieces
+}
+
+// splitTable splits a Markdown table into pieces of at most size runes
+// between rows, repeating the header rows on every piece that has room
+func splitTable(table string, size int) []string {
+	if utf8.RuneCountInString(table) <= size {
+		return []string{table}
+	}
+	rows := strings.Split(table, "\n")
+	header := ""
+	if len(rows) > 2 && strings.Trim(rows[1], "|-: ") == "" {
+		header = rows[0] + "\n" + rows[1]
+		rows = rows[2:]
+	}
+	if utf8.RuneCountInString(header) > size/2 {
+		header = ""
+	}
+
+	var pieces []string
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() > 0 {
+			pieces = append(pieces, buf.String())
+			buf.Reset()
+		}
+	}
+	for _, row := range rows {
+		if utf8.RuneCountInString(row) > size {
+			flush()
+			pieces = append(pieces, splitWords(row, size)...)
+			continue
+		}
+		if buf.Len() > 0 && utf8.RuneCountInString(buf.String())+1+utf8.RuneCountInString(row) > size {
+			flush()
+		}
+		if buf.Len() == 0 && header != "" && utf8.RuneCountInString(header)+1+utf8.RuneCountInString(row) <= size {
+			buf.WriteString(header)
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(row)
+	}
+	flush()
+	return pieces
+}
+
+// splitSentences splits text after ".", "?" or "!" followed by a space
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		if r != ' ' || i == 0 {
+			continue
+		}
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		if prev == '.' || prev == '?' || prev == '!' {
+			if s := strings.TrimSpace(text[start:i]); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// splitWords packs words into pieces of at most size runes; a single word
+// longer than size is cut between runes
+func splitWords(text string, size int) []string {
+	var pieces []string
+	var buf strings.Builder
+	bufLen := 0
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > size {
+			if bufLen > 0 {
+				pieces = append(pieces, buf.String())
+				buf.Reset()
+				bufLen = 0
+			}
+			pieces = append(pieces, string(runes[:size]))
+			runes = runes[size:]
+		}
+		if len(runes) == 0 {
+			continue
+		}
+		if bufLen > 0 && bufLen+1+len(runes) > size {
+			pieces = append(pieces, buf.String())
+			buf.Reset()
+			bufLen = 0
+		}
+		if bufLen > 0 {
+			buf.WriteByte(' ')
+			bufLen++
+		}
+		buf.WriteString(string(runes))
+		bufLen += len(runes)
+	}
+	if bufLen > 0 {
+		pieces = append(pieces, buf.String())
+	}
+	return pieces
+}
+
+// tailRunes returns at most n trailing runes of text, starting at a word boundary
+func tailRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	tail := runes[len(runes)-n:]
+	if !unicode.IsSpace(runes[len(runes)-n-1]) {
+		// Skip the partial word at the start
+		for i, r := range tail {
+			if unicode.IsSpace(r) {
+				tail = tail[i+1:]
+				break
+			}
+			if i == len(tail)-1 {
+				tail = tail[:0]
+			}
+		}
+	}
+	return strings.TrimSpace(string(tail))
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while the circuit breaker rejects calls
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned by CircuitBreaker.Allow while calls are being
+// rejected. RetryAfter is the time left until a probe call is allowed.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", ErrCircuitOpen, e.RetryAfter.Round(time.Second))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitState is the state of a circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitHealth is a snapshot of a circuit breaker for health reporting
+type CircuitHealth struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time   `json:"last_failure_at,omitempty"`
+	LastSuccessAt       *time.Time   `json:"last_success_at,omitempty"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker fails fast after repeated upstream failures. After
+// failureThreshold consecutive failures it opens and rejects calls for
+// openTimeout, then lets a single probe call through (half-open). A
+// successful probe closes the circuit; a failed one opens it again.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	openTimeout      time.Duration
+
+	state               CircuitState
+	consecutiveFailures int
+	probeInFlight       bool
+	openedAt            time.Time
+	lastError           string
+	lastFailureAt       time.Time
+	lastSuccessAt       time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+	if openTimeout <= 0 {
+		openTimeout = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            CircuitClosed,
+	}
+}
+
+// Allow reports whether a call may proceed, returning a CircuitOpenError if not
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if wait := cb.openTimeout - time.Since(cb.openedAt); wait > 0 {
+			return &CircuitOpenError{RetryAfter: wait}
+		}
+		// Cooldown elapsed: let one probe through
+		cb.state = CircuitHalfOpen
+		cb.probeInFlight = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.probeInFlight {
+			return &CircuitOpenError{RetryAfter: time.Second}
+		}
+		cb.probeInFlight = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// RecordSuccess closes the circuit and resets the failure count
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state = CircuitClosed
+	cb.consecutiveFailures = 0
+	cb.probeInFlight = false
+	cb.lastSuccessAt = time.Now()
+}
+
+// RecordFailure counts an upstream failure and opens the circuit when needed
+func (cb *CircuitBreaker) RecordFailure(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutiveFailures++
+	cb.lastFailureAt = time.Now()
+	if err != nil {
+		cb.lastError = err.Error()
+	}
+
+	if cb.state == CircuitHalfOpen || cb.consecutiveFailures >= cb.failureThreshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+		cb.probeInFlight = false
+	}
+}
+
+// Release ends a call that was allowed but produced no verdict, such as one
+// cancelled by the caller, so a half-open probe slot is not held forever
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probeInFlight = false
+}
+
+// Health returns a snapshot of the breaker state
+func (cb *CircuitBreaker) Health() CircuitHealth {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	health := CircuitHealth{
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		LastError:           cb.lastError,
+	}
+	if !cb.lastFailureAt.IsZero() {
+		t := cb.lastFailureAt
+		health.LastFailureAt = &t
+	}
+	if !cb.lastSuccessAt.IsZero() {
+		t := cb.lastSuccessAt
+		health.LastSuccessAt = &t
+	}
+	if cb.state != CircuitClosed {
+		t := cb.openedAt
+		health.OpenedAt = &t
+	}
+	return health
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// chatTopK excerpts are given to the model for each question
+	chatTopK = 5
+	// ChatHistoryMessages is how many earlier messages are replayed so
+	// follow-up questions keep their context
+	ChatHistoryMessages = 6
+	// defaultChatMinScore is the lowest best-chunk cosine similarity at
+	// which a question is considered covered by the document
+	defaultChatMinScore = 0.1
+	// chatNotFoundMarker is what the model replies when the excerpts do not
+	// contain the answer
+	chatNotFoundMarker = "NOT_IN_DOCUMENT"
+	// chatExcerptLength bounds the excerpt returned with each citation
+	chatExcerptLength = 300
+)
+
+// chatRefusal is the answer given instead of guessing
+const chatRefusal = "I couldn't find this in the document, so I can't answer it reliably. Try rephrasing the question or asking about a topic the document covers."
+
+var (
+	chatCitationPattern = regexp.MustCompile(`\[(\d+)\]`)
+	// chatCitationStripPattern matches a marker with the space before it
+	chatCitationStripPattern = regexp.MustCompile(`[ \t]*\[\d+\]`)
+)
+
+// AnswerDocumentQuestion answers a question about doc from its own chunks,
+// citing the excerpts it used. When retrieval finds nothing similar enough
+// to the question, or the model reports the excerpts do not answer it, the
+// returned message is a refusal instead. history holds earlier messages of
+// the conversation, oldest first.
+func (ai *AIService) AnswerDocumentQuestion(ctx context.Context, doc *models.Document, question string, history []models.DocumentChatMessage) (*models.DocumentChatMessage, error) {
+	if !ai.useSenopati {
+		return nil, fmt.Errorf("no AI provider configured - Senopati is required")
+	}
+
+	if err := ai.rag.EnsureIndexed(ctx, doc.UserID, doc.ID, documentPages(doc)); err != nil {
+		return nil, fmt.Errorf("failed to index document: %w", err)
+	}
+	chunks, err := ai.rag.Retrieve(ctx, question, chatTopK, RetrieveOptions{
+		Filter:    VectorFilter{UserID: doc.UserID, DocumentIDs: []string{doc.ID}},
+		MMRLambda: ai.mmrLambda,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve document context: %w", err)
+	}
+
+	answer := &models.DocumentChatMessage{
+		ID:         uuid.New().String(),
+		DocumentID: doc.ID,
+		UserID:     doc.UserID,
+		Role:       "assistant",
+	}
+
+	confidence := 0.0
+	for _, ch := range chunks {
+		confidence = math.Max(confidence, ch.VectorScore)
+	}
+	if len(chunks) == 0 || confidence < ai.chatMinScore {
+		ai.logger.Infof("Refusing document question: best chunk similarity %.3f below %.3f", confidence, ai.chatMinScore)
+		answer.Content = chatRefusal
+		answer.Refused = true
+		answer.CreatedAt = time.Now()
+		return answer, nil
+	}
+
+	messages := []ChatMessage{{Role: "system", Content: ai.buildDocumentChatSystemPrompt(doc.Title)}}
+	for _, msg := range history {
+		// Earlier answers cite excerpts of their own turn; left in, their [n]
+		// would be read as references to this turn's excerpts
+		messages = append(messages, ChatMessage{Role: msg.Role, Content: stripCitations(msg.Content)})
+	}
+	messages = append(messages, ChatMessage{Role: "user", Content: buildDocumentChatQuestion(chunks, question)})
+
+	model := ai.selectSenopatiModel(ctx)
+	var resp *ChatResponse
+	err = ai.scheduler.Do(ctx, doc.UserID, func() error {
+		var err error
+		resp, err = ai.senopatiClient.Chat(ctx, model, messages, 0.2, 1000)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("senopati API error: %w", err)
+	}
+
+	content := strings.TrimSpace(resp.Message.Content)
+	answer.CreatedAt = time.Now()
+	if content == "" || strings.Contains(content, chatNotFoundMarker) {
+		answer.Content = chatRefusal
+		answer.Refused = true
+		return answer, nil
+	}
+
+	answer.Content = content
+	answer.Citations = citeChunks(content, chunks)
+	return answer, nil
+}
+
+func (ai *AIService) buildDocumentChatSystemPrompt(title string) string {
+	return fmt.Sprintf(`You are a study assistant answering a student's questions about the document "%s".
+
+Rules:
+- Answer ONLY from the numbered excerpts given with each question; do not use outside knowledge
+- Cite the excerpts you use with their numbers in square brackets, e.g. [1] or [2][3]
+- If the excerpts do not contain the answer, reply with exactly %s and nothing else
+- Be concise and answer in the same language as the question`, title, chatNotFoundMarker)
+}
+
+// buildDocumentChatQuestion numbers the retrieved chunks with their page
+// and section so the model can cite them
+func buildDocumentChatQuestion(chunks []VectorItem, question string) string {
+	var b strings.Builder
+	b.WriteString("Excerpts:\n")
+	for i, ch := range chunks {
+		b.WriteString("\n[" + strconv.Itoa(i+1) + "]")
+		if label := chunkLocation(ch); label != "" {
+			b.WriteString(" (" + label + ")")
+		}
+		b.WriteString("\n" + strings.TrimSpace(ch.Text) + "\n")
+	}
+	b.WriteString("\nQuestion: " + question)
+	return b.String()
+}
+
+// chunkLocation describes where a chunk sits, e.g. `page 3, "Methods"`
+func chunkLocation(ch VectorItem) string {
+	var parts []string
+	if ch.Page > 0 {
+		parts = append(parts, "page "+strconv.Itoa(ch.Page))
+	}
+	if ch.Section != "" {
+		parts = append(parts, strconv.Quote(ch.Section))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stripCitations removes the [n] excerpt markers from a message
+func stripCitations(content string) string {
+	return chatCitationStripPattern.ReplaceAllString(content, "")
+}
+
+// citeChunks returns a citation for every excerpt referenced as [n] in the
+// answer, or for all excerpts when the model did not cite any
+func citeChunks(answer string, chunks []VectorItem) []models.Citation {
+	cited := make(map[int]bool)
+	var refs []int
+	for _, m := range chatCitationPattern.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > len(chunks) || cited[n] {
+			continue
+		}
+		cited[n] = true
+		refs = append(refs, n)
+	}
+	if len(refs) == 0 {
+		for i := range chunks {
+			refs = append(refs, i+1)
+		}
+	}
+
+	citations := make([]models.Citation, 0, len(refs))
+	for _, n := range refs {
+		ch := chunks[n-1]
+		excerpt := strings.TrimSpace(ch.Text)
+		if utf8.RuneCountInString(excerpt) > chatExcerptLength {
+			excerpt = string([]rune(excerpt)[:chatExcerptLength]) + "..."
+		}
+		citations = append(citations, models.Citation{
+			Ref:     n,
+			Page:    ch.Page,
+			Section: ch.Section,
+			Excerpt: excerpt,
+			Score:   ch.VectorScore,
+		})
+	}
+	return citations
+}
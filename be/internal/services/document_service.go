@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"pbkk-quizlit-backend/internal/models"
+	"pbkk-quizlit-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrDocumentNotFound is returned when a document ID is unknown
+var ErrDocumentNotFound = repository.ErrDocumentNotFound
+
+// DocumentService stores the source material of generated quizzes, the
+// original upload together with its extracted text, so that more quizzes,
+// study notes and chat answers can be generated from it without a re-upload
+type DocumentService struct {
+	repo        *repository.DocumentRepository
+	fileService *FileService
+
+	mu sync.RWMutex
+	// onDelete is called with the ID of every deleted document
+	onDelete func(documentID string)
+}
+
+func NewDocumentService(fileService *FileService) *DocumentService {
+	return &DocumentService{
+		repo:        repository.NewDocumentRepository(),
+		fileService: fileService,
+	}
+}
+
+// SetDeletionHandler registers fn to be called whenever a document is
+// deleted, so derived data such as RAG chunks can be dropped too
+func (ds *DocumentService) SetDeletionHandler(fn func(documentID string)) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.onDelete = fn
+}
+
+// CreateFromFile extracts and stores an uploaded file. When the user has
+// already uploaded the same file, the stored document is returned instead
+// and created is false.
+func (ds *DocumentService) CreateFromFile(ctx context.Context, userID, title, filename string, data []byte) (doc *models.Document, created bool, err error) {
+	hash := contentHash(data)
+	if doc, err := ds.repo.FindByHash(ctx, userID, hash); err == nil {
+		return doc, false, nil
+	} else if !errors.Is(err, ErrDocumentNotFound) {
+		return nil, false, err
+	}
+
+	extracted, err := ds.fileService.ExtractFile(data, filename)
+	if err != nil {
+		return nil, false, err
+	}
+
+	doc = &models.Document{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Title:       strings.TrimSpace(title),
+		Filename:    filename,
+		ContentType: extracted.ContentType,
+		Size:        int64(len(data)),
+		Hash:        hash,
+		PageCount:   extracted.Metadata.PageCount,
+		Content:     extracted.Text,
+		Pages:       extracted.Pages,
+		Sections:    extracted.Sections,
+		CreatedAt:   time.Now(),
+	}
+	// Untitled uploads take the title the file gives itself, or its name
+	if doc.Title == "" {
+		doc.Title = extracted.Metadata.Title
+	}
+	if doc.Title == "" {
+		doc.Title = filename
+	}
+	if err := ds.repo.CreateDocument(ctx, doc, data); err != nil {
+		// The same file may have been stored by a concurrent upload
+		if existing, findErr := ds.repo.FindByHash(ctx, userID, hash); findErr == nil {
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+	return doc, true, nil
+}
+
+// CreateFromText stores pasted text content, returning the user's stored
+// document when the same text was pasted before
+func (ds *DocumentService) CreateFromText(ctx context.Context, userID, title, content string) (*models.Document, error) {
+	hash := contentHash([]byte(content))
+	if doc, err := ds.repo.FindByHash(ctx, userID, hash); err == nil {
+		return doc, nil
+	} else if !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	doc := &models.Document{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Title:       strings.TrimSpace(title),
+		ContentType: "text/plain; charset=utf-8",
+		Hash:        hash,
+		Content:     content,
+		CreatedAt:   time.Now(),
+	}
+	if err := ds.repo.CreateDocument(ctx, doc, nil); err != nil {
+		if existing, findErr := ds.repo.FindByHash(ctx, userID, hash); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return doc, nil
+}
+
+// DeleteDocument removes a document and its study notes. Quizzes generated
+// from it are kept.
+func (ds *DocumentService) DeleteDocument(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrDocumentNotFound
+	}
+	if err := ds.repo.DeleteDocument(ctx, id); err != nil {
+		return err
+	}
+
+	ds.mu.RLock()
+	onDelete := ds.onDelete
+	ds.mu.RUnlock()
+	if onDelete != nil {
+		onDelete(id)
+	}
+	return nil
+}
+
+// GetDocument returns a document by ID
+func (ds *DocumentService) GetDocument(ctx context.Context, id string) (*models.Document, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrDocumentNotFound
+	}
+	return ds.repo.GetDocument(ctx, id)
+}
+
+// ListDocuments returns a user's documents, newest first, without their text
+func (ds *DocumentService) ListDocuments(ctx context.Context, userID string) ([]*models.Document, error) {
+	return ds.repo.ListDocuments(ctx, userID)
+}
+
+// GetFile returns the original upload of a document, or nil for pasted text
+func (ds *DocumentService) GetFile(ctx context.Context, id string) ([]byte, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrDocumentNotFound
+	}
+	return ds.repo.GetFileData(ctx, id)
+}
+
+// GetStudyNotes returns the stored study notes for a document, if any
+func (ds *DocumentService) GetStudyNotes(ctx context.Context, documentID string) (*models.StudyNotes, bool, error) {
+	notes, err := ds.repo.GetStudyNotes(ctx, documentID)
+	if err != nil {
+		return nil, false, err
+	}
+	return notes, notes != nil, nil
+}
+
+// SaveStudyNotes stores study notes with their document
+func (ds *DocumentService) SaveStudyNotes(ctx context.Context, notes *models.StudyNotes) error {
+	return ds.repo.SaveStudyNotes(ctx, notes)
+}
+
+// contentHash returns the hex SHA-256 of data
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// BatchEmbedder is implemented by providers that can embed several texts per call
+type BatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// EmbeddingOptions selects and configures an embedding provider
+type EmbeddingOptions struct {
+	// Provider is one of "local", "ollama", "openai" or "hash"
+	Provider string
+	BaseURL  string
+	Model    string
+	APIKey   string
+	// BatchSize is the number of texts sent per HTTP request
+	BatchSize int
+	// CacheSize is the number of embeddings kept in memory; 0 disables the cache
+	CacheSize int
+}
+
+// NewEmbeddingProvider builds the provider described by opts
+func NewEmbeddingProvider(opts EmbeddingOptions) (EmbeddingProvider, error) {
+	var provider EmbeddingProvider
+	switch strings.ToLower(strings.TrimSpace(opts.Provider)) {
+	case "", "local":
+		provider = NewLocalEmbedding(defaultLocalEmbeddingDims)
+	case "hash":
+		provider = HashEmbedding{}
+	case "ollama", "openai":
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("embedding provider %q requires a base URL", opts.Provider)
+		}
+		if opts.Model == "" {
+			return nil, fmt.Errorf("embedding provider %q requires a model", opts.Provider)
+		}
+		provider = NewHTTPEmbedding(strings.ToLower(opts.Provider), opts.BaseURL, opts.Model, opts.APIKey, opts.BatchSize)
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", opts.Provider)
+	}
+
+	if opts.CacheSize > 0 {
+		provider = NewCachedEmbedding(provider, opts.CacheSize)
+	}
+	return provider, nil
+}
+
+// embedAll embeds texts, batching when the provider supports it
+func embedAll(ctx context.Context, embedder EmbeddingProvider, texts []string) ([][]float64, error) {
+	if batcher, ok := embedder.(BatchEmbedder); ok {
+		return batcher.EmbedBatch(ctx, texts)
+	}
+	out := make([][]float64, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		emb, err := embedder.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = emb
+	}
+	return out, nil
+}
+
+const defaultEmbeddingBatchSize = 32
+
+// HTTPEmbedding calls an embeddings API: Ollama's /api/embed or an
+// OpenAI-compatible /embeddings endpoint
+type HTTPEmbedding struct {
+	Format     string // "ollama" or "openai"
+	BaseURL    string
+	Model      string
+	APIKey     string
+	BatchSize  int
+	HTTPClient *http.Client
+}
+
+func NewHTTPEmbedding(format, baseURL, model, apiKey string, batchSize int) *HTTPEmbedding {
+	if batchSize <= 0 {
+		batchSize = defaultEmbeddingBatchSize
+	}
+	return &HTTPEmbedding{
+		Format:    format,
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		Model:     model,
+		APIKey:    apiKey,
+		BatchSize: batchSize,
+		HTTPClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+func (h *HTTPEmbedding) Embed(ctx context.Context, text string) ([]float64, error) {
+	out, err := h.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// EmbedBatch embeds texts in requests of at most BatchSize inputs
+func (h *HTTPEmbedding) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	out := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += h.BatchSize {
+		end := min(start+h.BatchSize, len(texts))
+		batch, err := h.request(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("embedding API returned %d embeddings for %d inputs", len(batch), end-start)
+		}
+		out = append(out, batch...)
+	}
+	return out, nil
+}
+
+func (h *HTTPEmbedding) request(ctx context.Context, texts []string) ([][]float64, error) {
+	path := "/embeddings"
+	if h.Format == "ollama" {
+		path = "/api/embed"
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": h.Model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", h.BaseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.APIKey)
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("embedding API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	if h.Format == "ollama" {
+		var result struct {
+			Embeddings [][]float64 `json:"embeddings"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+		}
+		return result.Embeddings, nil
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	// OpenAI does not promise the order of data; place each by its index
+	out := make([][]float64, len(result.Data))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embedding response index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
+const defaultLocalEmbeddingDims = 1024
+
+// LocalEmbedding is a network-free lexical embedding using the hashing
+// trick: words and word bigrams are hashed into a fixed number of signed
+// buckets with sublinear term frequency, and common English and Indonesian
+// stopwords are dropped as a stand-in for IDF. Texts that share vocabulary
+// end up close under cosine similarity, which is enough to rank chunks
+// against a query without an external model.
+type LocalEmbedding struct {
+	dims int
+}
+
+func NewLocalEmbedding(dims int) *LocalEmbedding {
+	if dims <= 0 {
+		dims = defaultLocalEmbeddingDims
+	}
+	return &LocalEmbedding{dims: dims}
+}
+
+func (l *LocalEmbedding) Embed(_ context.Context, text string) ([]float64, error) {
+	tokens := embeddingTokens(text)
+
+	counts := make(map[string]int)
+	for i, tok := range tokens {
+		counts[tok]++
+		if i > 0 {
+			counts[tokens[i-1]+" "+tok]++
+		}
+	}
+
+	vec := make([]float64, l.dims)
+	for term, n := range counts {
+		h := fnv.New32a()
+		h.Write([]byte(term))
+		sum := h.Sum32()
+		weight := 1 + math.Log(float64(n))
+		// Bigrams carry more specific meaning than single words
+		if strings.Contains(term, " ") {
+			weight *= 0.5
+		}
+		// The top bit picks the sign so collisions tend to cancel out
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vec[int(sum%uint32(l.dims))] += weight
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vec {
+			vec[i] /= norm
+		}
+	}
+	return vec, nil
+}
+
+// embeddingTokens lowercases text and splits it into words, dropping
+// stopwords and single characters
+func embeddingTokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) < 2 || embeddingStopwords[w] {
+			continue
+		}
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
+
+var embeddingStopwords = func() map[string]bool {
+	words := strings.Fields(`
+		a an and are as at be but by for from has have in is it its of on or that the
+		this to was were which will with not no can do does than then there these those
+		they we you he she i our your their what when where who how why also been into
+		yang dan di ke dari untuk dengan pada adalah ini itu dalam atau juga tidak akan
+		oleh sebagai karena bahwa ada sudah dapat bisa lebih para secara serta tersebut
+		yaitu agar maka jika saat telah hanya harus antara setiap seperti kami kita mereka
+		ia dia saya anda apa bagaimana mengapa siapa kapan dimana`)
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
+
+// CachedEmbedding memoizes another provider's embeddings by text, so
+// re-indexing a document or repeating a query does not embed it again.
+// Entries are evicted oldest first once maxEntries is reached.
+type CachedEmbedding struct {
+	inner      EmbeddingProvider
+	maxEntries int
+
+	mu    sync.Mutex
+	cache map[[32]byte][]float64
+	order [][32]byte
+}
+
+func NewCachedEmbedding(inner EmbeddingProvider, maxEntries int) *CachedEmbedding {
+	return &CachedEmbedding{
+		inner:      inner,
+		maxEntries: maxEntries,
+		cache:      make(map[[32]byte][]float64),
+	}
+}
+
+func (c *CachedEmbedding) Embed(ctx context.Context, text string) ([]float64, error) {
+	out, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// EmbedBatch serves cached embeddings and embeds only the misses
+func (c *CachedEmbedding) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	out := make([][]float64, len(texts))
+	keys := make([][32]byte, len(texts))
+	var missTexts []string
+	var missIdx []int
+
+	c.mu.Lock()
+	for i, text := range texts {
+		keys[i] = sha256.Sum256([]byte(text))
+		if emb, ok := c.cache[keys[i]]; ok {
+			out[i] = emb
+			continue
+		}
+		missTexts = append(missTexts, text)
+		missIdx = append(missIdx, i)
+	}
+	c.mu.Unlock()
+
+	if len(missTexts) == 0 {
+		return out, nil
+	}
+
+	embedded, err := embedAll(ctx, c.inner, missTexts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for j, i := range missIdx {
+		out[i] = embedded[j]
+		if _, exists := c.cache[keys[i]]; exists {
+			continue
+		}
+		if len(c.order) >= c.maxEntries {
+			delete(c.cache, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.cache[keys[i]] = embedded[j]
+		c.order = append(c.order, keys[i])
+	}
+	return out, nil
+}
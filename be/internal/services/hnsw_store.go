@@ -0,0 +1,607 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+	// hnswBruteForceLimit is the number of matching chunks below which a
+	// filtered search scores them directly instead of walking the graph
+	hnswBruteForceLimit = 2000
+)
+
+// HNSWVectorStore keeps embeddings in process memory behind a hierarchical
+// navigable small world graph, so TopK visits a few hundred nodes instead of
+// scoring every chunk. Results are approximate; recall is tuned by efSearch.
+//
+// Filters are applied during the graph walk: nodes outside the filter still
+// route the search but never enter the results. When a filter matches only
+// a few chunks, such as a single document, they are scored exhaustively.
+// Deleted and replaced chunks are tombstoned and the graph is rebuilt once
+// tombstones outnumber live nodes; searches keep using the old graph while
+// the new one is built. Embeddings of different dimensions, as
+// after switching providers, live in separate graphs.
+type HNSWVectorStore struct {
+	mu             sync.RWMutex
+	m              int
+	efConstruction int
+	efSearch       int
+	rng            *rand.Rand
+
+	graphs map[int]*hnswGraph
+	byID   map[string]hnswRef
+	byDoc  map[string]map[string]struct{}
+}
+
+// hnswRef locates a chunk's node: the graph it lives in and its index there
+type hnswRef struct {
+	dims int
+	node int32
+}
+
+// NewHNSWVectorStore creates an empty index. m is the number of links per
+// node, efConstruction and efSearch the candidate list sizes used while
+// building and querying; zero values pick the defaults.
+func NewHNSWVectorStore(m, efConstruction, efSearch int) *HNSWVectorStore {
+	if m <= 1 {
+		m = defaultHNSWM
+	}
+	if efConstruction <= 0 {
+		efConstruction = defaultHNSWEfConstruction
+	}
+	if efSearch <= 0 {
+		efSearch = defaultHNSWEfSearch
+	}
+	return &HNSWVectorStore{
+		m:              m,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		rng:            rand.New(rand.NewSource(42)),
+		graphs:         make(map[int]*hnswGraph),
+		byID:           make(map[string]hnswRef),
+		byDoc:          make(map[string]map[string]struct{}),
+	}
+}
+
+func (vs *HNSWVectorStore) Upsert(_ context.Context, items []VectorItem) error {
+	vs.mu.Lock()
+	vs.upsert(items)
+	stale := vs.staleGraphs()
+	vs.mu.Unlock()
+
+	for _, dims := range stale {
+		vs.compact(dims)
+	}
+	return nil
+}
+
+func (vs *HNSWVectorStore) ReplaceDocument(_ context.Context, documentID string, items []VectorItem) error {
+	vs.mu.Lock()
+	vs.deleteDocument(documentID)
+	vs.upsert(items)
+	stale := vs.staleGraphs()
+	vs.mu.Unlock()
+
+	for _, dims := range stale {
+		vs.compact(dims)
+	}
+	return nil
+}
+
+// upsert inserts items, tombstoning the nodes they replace. The caller holds
+// the write lock.
+func (vs *HNSWVectorStore) upsert(items []VectorItem) {
+	for _, item := range items {
+		if ref, ok := vs.byID[item.ID]; ok {
+			vs.remove(item.ID, ref)
+		}
+		g := vs.graphs[len(item.Embedding)]
+		if g == nil {
+			g = newHNSWGraph(vs.m, vs.efConstruction)
+			vs.graphs[len(item.Embedding)] = g
+		}
+		node := g.insert(item, vs.rng)
+		vs.byID[item.ID] = hnswRef{dims: len(item.Embedding), node: node}
+		ids := vs.byDoc[item.DocumentID]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			vs.byDoc[item.DocumentID] = ids
+		}
+		ids[item.ID] = struct{}{}
+	}
+}
+
+// TopK returns the approximate top-k items by cosine similarity
+func (vs *HNSWVectorStore) TopK(_ context.Context, query []float64, k int, filter VectorFilter) ([]VectorItem, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	g := vs.graphs[len(query)]
+	if g == nil || k <= 0 {
+		return nil, nil
+	}
+
+	// Small filtered sets are cheaper and exact when scored directly
+	if len(filter.DocumentIDs) > 0 {
+		var refs []int32
+		for _, docID := range filter.DocumentIDs {
+			for id := range vs.byDoc[docID] {
+				if ref := vs.byID[id]; ref.dims == len(query) {
+					refs = append(refs, ref.node)
+				}
+			}
+		}
+		if len(refs) <= hnswBruteForceLimit {
+			candidates := make([]VectorItem, 0, len(refs))
+			for _, n := range refs {
+				if item := g.nodes[n].item; filter.matches(item) {
+					candidates = append(candidates, item)
+				}
+			}
+			return topKByCosine(candidates, query, k), nil
+		}
+	}
+
+	return g.search(query, k, max(vs.efSearch, k), filter), nil
+}
+
+func (vs *HNSWVectorStore) List(_ context.Context, filter VectorFilter) ([]VectorItem, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var items []VectorItem
+	collect := func(id string) {
+		ref := vs.byID[id]
+		if item := vs.graphs[ref.dims].nodes[ref.node].item; filter.matches(item) {
+			items = append(items, item)
+		}
+	}
+	if len(filter.DocumentIDs) > 0 {
+		for _, docID := range filter.DocumentIDs {
+			for id := range vs.byDoc[docID] {
+				collect(id)
+			}
+		}
+	} else {
+		for id := range vs.byID {
+			collect(id)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].DocumentID != items[j].DocumentID {
+			return items[i].DocumentID < items[j].DocumentID
+		}
+		return items[i].ChunkIndex < items[j].ChunkIndex
+	})
+	return items, nil
+}
+
+func (vs *HNSWVectorStore) DeleteDocument(_ context.Context, documentID string) error {
+	vs.mu.Lock()
+	vs.deleteDocument(documentID)
+	stale := vs.staleGraphs()
+	vs.mu.Unlock()
+
+	for _, dims := range stale {
+		vs.compact(dims)
+	}
+	return nil
+}
+
+// Len returns the number of live items
+func (vs *HNSWVectorStore) Len() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+	return len(vs.byID)
+}
+
+// deleteDocument removes every chunk of a document. The caller holds the
+// write lock.
+func (vs *HNSWVectorStore) deleteDocument(documentID string) {
+	for id := range vs.byDoc[documentID] {
+		vs.remove(id, vs.byID[id])
+	}
+}
+
+// remove tombstones a chunk and drops it from the lookup maps
+func (vs *HNSWVectorStore) remove(id string, ref hnswRef) {
+	g := vs.graphs[ref.dims]
+	docID := g.nodes[ref.node].item.DocumentID
+	g.tombstone(ref.node)
+
+	delete(vs.byID, id)
+	if ids := vs.byDoc[docID]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(vs.byDoc, docID)
+		}
+	}
+}
+
+// staleGraphs drops empty graphs and returns the dimensions of those whose
+// tombstones outnumber their live nodes. The caller holds the write lock.
+func (vs *HNSWVectorStore) staleGraphs() []int {
+	var stale []int
+	for dims, g := range vs.graphs {
+		if g.live == 0 {
+			delete(vs.graphs, dims)
+			continue
+		}
+		if g.deleted >= 64 && g.deleted > g.live && !g.rebuilding {
+			stale = append(stale, dims)
+		}
+	}
+	return stale
+}
+
+// compact rebuilds the graph of the given dimension from its live nodes.
+// The rebuild runs without the lock so searches carry on against the old
+// graph; chunks inserted or deleted meanwhile are replayed onto the new
+// graph before it is swapped in.
+func (vs *HNSWVectorStore) compact(dims int) {
+	vs.mu.Lock()
+	g := vs.graphs[dims]
+	if g == nil || g.rebuilding {
+		vs.mu.Unlock()
+		return
+	}
+	g.rebuilding = true
+	snapshot := int32(len(g.nodes))
+	var live []int32
+	for i, n := range g.nodes {
+		if !n.deleted {
+			live = append(live, int32(i))
+		}
+	}
+	items := make([]VectorItem, len(live))
+	for i, n := range live {
+		items[i] = g.nodes[n].item
+	}
+	rng := rand.New(rand.NewSource(vs.rng.Int63()))
+	vs.mu.Unlock()
+
+	rebuilt := newHNSWGraph(vs.m, vs.efConstruction)
+	moved := make(map[int32]int32, len(items))
+	for i, item := range items {
+		moved[live[i]] = rebuilt.insert(item, rng)
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	g.rebuilding = false
+	if vs.graphs[dims] != g {
+		// The graph emptied and was dropped while rebuilding
+		return
+	}
+	for old, node := range moved {
+		if g.nodes[old].deleted {
+			rebuilt.tombstone(node)
+		}
+	}
+	for old := snapshot; old < int32(len(g.nodes)); old++ {
+		if !g.nodes[old].deleted {
+			moved[old] = rebuilt.insert(g.nodes[old].item, vs.rng)
+		}
+	}
+	for old, node := range moved {
+		if n := g.nodes[old]; !n.deleted {
+			vs.byID[n.item.ID] = hnswRef{dims: dims, node: node}
+		}
+	}
+	vs.graphs[dims] = rebuilt
+}
+
+// hnswGraph is a single HNSW index over embeddings of one dimension
+type hnswGraph struct {
+	m              int
+	efConstruction int
+	levelMult      float64
+
+	nodes    []hnswNode
+	entry    int32
+	maxLevel int
+	live     int
+	deleted  int
+	// rebuilding is set while compact builds this graph's replacement
+	rebuilding bool
+}
+
+type hnswNode struct {
+	item VectorItem
+	norm float64
+	// links[l] are the neighbours on layer l
+	links   [][]int32
+	deleted bool
+}
+
+func newHNSWGraph(m, efConstruction int) *hnswGraph {
+	return &hnswGraph{
+		m:              m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		entry:          -1,
+	}
+}
+
+// maxLinks is the neighbour limit on a layer; the base layer is denser
+func (g *hnswGraph) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * g.m
+	}
+	return g.m
+}
+
+func (g *hnswGraph) insert(item VectorItem, rng *rand.Rand) int32 {
+	level := int(-math.Log(1-rng.Float64()) * g.levelMult)
+	id := int32(len(g.nodes))
+	g.nodes = append(g.nodes, hnswNode{
+		item:  item,
+		norm:  vectorNorm(item.Embedding),
+		links: make([][]int32, level+1),
+	})
+	g.live++
+
+	if g.entry < 0 {
+		g.entry = id
+		g.maxLevel = level
+		return id
+	}
+
+	query := item.Embedding
+	qNorm := g.nodes[id].norm
+	ep := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		ep = g.greedy(query, qNorm, ep, l)
+	}
+
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(query, qNorm, ep, g.efConstruction, l, nil)
+		neighbours := g.selectNeighbours(candidates, g.maxLinks(l))
+		g.nodes[id].links[l] = neighbours
+		for _, n := range neighbours {
+			g.link(n, id, l)
+		}
+		if len(candidates) > 0 {
+			ep = candidates[0].node
+		}
+	}
+
+	if level > g.maxLevel {
+		g.maxLevel = level
+		g.entry = id
+	}
+	return id
+}
+
+// link adds a directed edge from -> to on layer l. Once from has a quarter
+// more neighbours than allowed they are reselected with the same heuristic
+// as a new node's; pruning in bursts keeps inserts from paying for the
+// heuristic on every back link.
+func (g *hnswGraph) link(from, to int32, l int) {
+	links := append(g.nodes[from].links[l], to)
+	if len(links) <= g.maxLinks(l)+g.maxLinks(l)/4 {
+		g.nodes[from].links[l] = links
+		return
+	}
+
+	base := g.nodes[from]
+	scored := make([]hnswCandidate, len(links))
+	for i, n := range links {
+		scored[i] = hnswCandidate{node: n, sim: g.similarity(base.item.Embedding, base.norm, n)}
+	}
+	sort.Slice(scored, func(i, j int) bool { return scored[i].sim > scored[j].sim })
+	g.nodes[from].links[l] = g.selectNeighbours(scored, g.maxLinks(l))
+}
+
+// selectNeighbours picks up to m of candidates (best first) with the HNSW
+// heuristic: a candidate is skipped when it is closer to an already chosen
+// neighbour than to the base node, which keeps links pointing in different
+// directions. Skipped candidates fill any remaining slots.
+func (g *hnswGraph) selectNeighbours(candidates []hnswCandidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		cn := g.nodes[c.node]
+		for _, s := range selected {
+			if g.similarity(cn.item.Embedding, cn.norm, s) > c.sim {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+// greedy walks layer l from ep towards query and returns the closest node found
+func (g *hnswGraph) greedy(query []float64, qNorm float64, ep int32, l int) int32 {
+	best := g.similarity(query, qNorm, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range g.nodes[ep].links[l] {
+			if sim := g.similarity(query, qNorm, n); sim > best {
+				best, ep, changed = sim, n, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer is the HNSW beam search on layer l. It returns up to ef nodes
+// best first; when accept is set only accepted nodes are returned, though
+// every node is still used for routing.
+func (g *hnswGraph) searchLayer(query []float64, qNorm float64, ep int32, ef, l int, accept func(hnswNode) bool) []hnswCandidate {
+	visited := map[int32]struct{}{ep: {}}
+	start := hnswCandidate{node: ep, sim: g.similarity(query, qNorm, ep)}
+
+	frontier := &candidateHeap{max: true}
+	heap.Push(frontier, start)
+	results := &candidateHeap{}
+	if accept == nil || accept(g.nodes[ep]) {
+		heap.Push(results, start)
+	}
+
+	for frontier.Len() > 0 {
+		c := heap.Pop(frontier).(hnswCandidate)
+		if results.Len() >= ef && c.sim < results.items[0].sim {
+			break
+		}
+		for _, n := range g.nodes[c.node].links[l] {
+			if _, seen := visited[n]; seen {
+				continue
+			}
+			visited[n] = struct{}{}
+
+			sim := g.similarity(query, qNorm, n)
+			if results.Len() < ef || sim > results.items[0].sim {
+				heap.Push(frontier, hnswCandidate{node: n, sim: sim})
+				if accept == nil || accept(g.nodes[n]) {
+					heap.Push(results, hnswCandidate{node: n, sim: sim})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]hnswCandidate, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return out
+}
+
+// search returns the k live nodes matching filter that are closest to query
+func (g *hnswGraph) search(query []float64, k, ef int, filter VectorFilter) []VectorItem {
+	if g.entry < 0 {
+		return nil
+	}
+	qNorm := vectorNorm(query)
+	if qNorm == 0 {
+		return nil
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedy(query, qNorm, ep, l)
+	}
+
+	accept := func(n hnswNode) bool {
+		return !n.deleted && filter.matches(n.item)
+	}
+	// A selective filter can starve the beam; widen it until k results
+	// are found or the whole graph has been considered
+	var found []hnswCandidate
+	for {
+		found = g.searchLayer(query, qNorm, ep, ef, 0, accept)
+		if len(found) >= k || ef >= len(g.nodes) {
+			break
+		}
+		ef *= 4
+	}
+
+	if len(found) > k {
+		found = found[:k]
+	}
+	items := make([]VectorItem, len(found))
+	for i, c := range found {
+		items[i] = g.nodes[c.node].item
+		items[i].Score = c.sim
+	}
+	return items
+}
+
+func (g *hnswGraph) tombstone(id int32) {
+	if g.nodes[id].deleted {
+		return
+	}
+	g.nodes[id].deleted = true
+	g.live--
+	g.deleted++
+}
+
+// similarity is the cosine similarity between query and node n
+func (g *hnswGraph) similarity(query []float64, qNorm float64, n int32) float64 {
+	node := &g.nodes[n]
+	if qNorm == 0 || node.norm == 0 {
+		return 0
+	}
+	return dotProduct(query, node.item.Embedding) / (qNorm * node.norm)
+}
+
+// dotProduct is the dot product of two equally long vectors, unrolled since it
+// dominates both building and searching the graph
+func dotProduct(a, b []float64) float64 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+func vectorNorm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+type hnswCandidate struct {
+	node int32
+	sim  float64
+}
+
+// candidateHeap orders candidates by similarity, most similar on top when
+// max is set and least similar otherwise
+type candidateHeap struct {
+	items []hnswCandidate
+	max   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].sim > h.items[j].sim
+	}
+	return h.items[i].sim < h.items[j].sim
+}
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(hnswCandidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// clusteredItems returns n unit embeddings spread around a few topics, as
+// chunks of related documents are, split into 50-chunk documents owned by
+// ten users
+func clusteredItems(rng *rand.Rand, n, dims int, centers [][]float64) []VectorItem {
+	items := make([]VectorItem, n)
+	for i := range items {
+		items[i] = VectorItem{
+			ID:         fmt.Sprintf("doc-%d:%d", i/50, i%50),
+			DocumentID: fmt.Sprintf("doc-%d", i/50),
+			UserID:     fmt.Sprintf("user-%d", (i/50)%10),
+			ChunkIndex: i % 50,
+			Embedding:  noisyVector(rng, centers[rng.Intn(len(centers))], 0.6),
+		}
+	}
+	return items
+}
+
+// noisyVector returns a unit vector near center
+func noisyVector(rng *rand.Rand, center []float64, noise float64) []float64 {
+	v := make([]float64, len(center))
+	var norm float64
+	for i := range v {
+		v[i] = center[i] + rng.NormFloat64()*noise/math.Sqrt(float64(len(v)))
+		norm += v[i] * v[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func benchCorpus(n, dims, queries int) ([]VectorItem, [][]float64) {
+	rng := rand.New(rand.NewSource(1))
+	centers := make([][]float64, 32)
+	for i := range centers {
+		centers[i] = noisyVector(rng, make([]float64, dims), float64(dims))
+	}
+	qs := make([][]float64, queries)
+	for i := range qs {
+		qs[i] = noisyVector(rng, centers[rng.Intn(len(centers))], 0.6)
+	}
+	return clusteredItems(rng, n, dims, centers), qs
+}
+
+func TestHNSWRecallAgainstBruteForce(t *testing.T) {
+	ctx := context.Background()
+	items, queries := benchCorpus(5000, 64, 100)
+	const k = 10
+
+	exact := NewMemoryVectorStore()
+	approx := NewHNSWVectorStore(0, 0, 0)
+	for _, store := range []VectorStore{exact, approx} {
+		if err := store.Upsert(ctx, items); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+
+	for _, filter := range []VectorFilter{{}, {UserID: "user-3"}} {
+		var hits, total int
+		for _, q := range queries {
+			want, err := exact.TopK(ctx, q, k, filter)
+			if err != nil {
+				t.Fatalf("brute force TopK: %v", err)
+			}
+			got, err := approx.TopK(ctx, q, k, filter)
+			if err != nil {
+				t.Fatalf("hnsw TopK: %v", err)
+			}
+			found := make(map[string]bool, len(got))
+			for _, it := range got {
+				if filter.UserID != "" && it.UserID != filter.UserID {
+					t.Fatalf("result %s belongs to %s, filtered to %s", it.ID, it.UserID, filter.UserID)
+				}
+				found[it.ID] = true
+			}
+			for _, it := range want {
+				total++
+				if found[it.ID] {
+					hits++
+				}
+			}
+		}
+
+		recall := float64(hits) / float64(total)
+		if recall < 0.9 {
+			t.Errorf("recall@%d with filter %+v = %.3f, want at least 0.9", k, filter, recall)
+		}
+	}
+}
+
+func TestHNSWCompactionKeepsLiveItems(t *testing.T) {
+	ctx := context.Background()
+	items, queries := benchCorpus(2000, 32, 20)
+
+	store := NewHNSWVectorStore(0, 0, 0)
+	if err := store.Upsert(ctx, items); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	// Deleting most documents tombstones enough nodes to rebuild the graph
+	for d := 0; d < 30; d++ {
+		if err := store.DeleteDocument(ctx, fmt.Sprintf("doc-%d", d)); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+	}
+	if got, want := store.Len(), 2000-30*50; got != want {
+		t.Fatalf("Len() = %d, want %d", got, want)
+	}
+
+	for _, q := range queries {
+		top, err := store.TopK(ctx, q, 5, VectorFilter{})
+		if err != nil {
+			t.Fatalf("TopK: %v", err)
+		}
+		if len(top) != 5 {
+			t.Fatalf("TopK returned %d items, want 5", len(top))
+		}
+		for _, it := range top {
+			var doc int
+			fmt.Sscanf(it.DocumentID, "doc-%d", &doc)
+			if doc < 30 {
+				t.Fatalf("TopK returned deleted item %s", it.ID)
+			}
+		}
+	}
+
+	listed, err := store.List(ctx, VectorFilter{DocumentIDs: []string{"doc-35"}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != 50 {
+		t.Fatalf("List returned %d items for doc-35, want 50", len(listed))
+	}
+}
+
+func BenchmarkTopK(b *testing.B) {
+	ctx := context.Background()
+	items, queries := benchCorpus(20000, 128, 200)
+
+	stores := []struct {
+		name  string
+		store VectorStore
+	}{
+		{"memory", NewMemoryVectorStore()},
+		{"hnsw", NewHNSWVectorStore(0, 0, 0)},
+	}
+	for _, s := range stores {
+		if err := s.store.Upsert(ctx, items); err != nil {
+			b.Fatalf("upsert: %v", err)
+		}
+		for _, filter := range []VectorFilter{{}, {UserID: "user-3"}} {
+			name := s.name
+			if filter.UserID != "" {
+				name += "/filtered"
+			}
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := s.store.TopK(ctx, queries[i%len(queries)], 10, filter); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
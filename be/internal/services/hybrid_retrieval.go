@@ -0,0 +1,132 @@
+package services
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// rrfK dampens the advantage of top ranks in reciprocal rank fusion
+	rrfK = 60
+	// bm25K1 and bm25B are the usual BM25 term saturation and length normalisation
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// defaultLexicalWeight gives keyword and vector ranks equal say
+	defaultLexicalWeight = 0.5
+	// maxLexicalScanDocuments is the most documents a filter may name for
+	// BM25 to rank all of their chunks
+	maxLexicalScanDocuments = 8
+	// lexicalPoolFactor widens the vector candidates BM25 ranks when the
+	// whole collection is searched
+	lexicalPoolFactor = 5
+)
+
+// bm25Scores scores every item's text against query with BM25, using the
+// items themselves as the corpus for document frequencies
+func bm25Scores(query string, items []VectorItem) []float64 {
+	scores := make([]float64, len(items))
+	terms := embeddingTokens(query)
+	if len(terms) == 0 || len(items) == 0 {
+		return scores
+	}
+
+	docs := make([]map[string]int, len(items))
+	lengths := make([]int, len(items))
+	df := make(map[string]int)
+	totalLength := 0
+	for i, it := range items {
+		tokens := embeddingTokens(it.Text)
+		tf := make(map[string]int)
+		for _, tok := range tokens {
+			tf[tok]++
+		}
+		for tok := range tf {
+			df[tok]++
+		}
+		docs[i] = tf
+		lengths[i] = len(tokens)
+		totalLength += len(tokens)
+	}
+	avgLength := float64(totalLength) / float64(len(items))
+	if avgLength == 0 {
+		return scores
+	}
+
+	n := float64(len(items))
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		// Repeated query words should not count twice
+		if seen[term] || df[term] == 0 {
+			continue
+		}
+		seen[term] = true
+		idf := math.Log(1 + (n-float64(df[term])+0.5)/(float64(df[term])+0.5))
+		for i, tf := range docs {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(lengths[i])/avgLength)
+			scores[i] += idf * f * (bm25K1 + 1) / (f + norm)
+		}
+	}
+	return scores
+}
+
+// lexicalTopK returns up to k items with a positive BM25 score, best first
+func lexicalTopK(query string, items []VectorItem, k int) []VectorItem {
+	scores := bm25Scores(query, items)
+	var ranked []VectorItem
+	for i, it := range items {
+		if scores[i] > 0 {
+			it.LexicalScore = scores[i]
+			ranked = append(ranked, it)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].LexicalScore > ranked[j].LexicalScore })
+	if k < len(ranked) {
+		ranked = ranked[:k]
+	}
+	return ranked
+}
+
+// fuseRankings merges a vector ranking and a lexical ranking with weighted
+// reciprocal rank fusion. lexicalWeight is the share of the lexical ranking
+// (0 to 1). Results carry the fused Score plus both underlying scores.
+func fuseRankings(vector, lexical []VectorItem, lexicalWeight float64, k int) []VectorItem {
+	fused := make(map[string]*VectorItem)
+	var order []string
+	add := func(it VectorItem, rank int, weight float64, isVector bool) {
+		entry, ok := fused[it.ID]
+		if !ok {
+			copied := it
+			copied.Score = 0
+			entry = &copied
+			fused[it.ID] = entry
+			order = append(order, it.ID)
+		}
+		if isVector {
+			entry.VectorScore = it.Score
+		} else {
+			entry.LexicalScore = it.LexicalScore
+		}
+		entry.Score += weight / float64(rrfK+rank+1)
+	}
+
+	for rank, it := range vector {
+		add(it, rank, 1-lexicalWeight, true)
+	}
+	for rank, it := range lexical {
+		add(it, rank, lexicalWeight, false)
+	}
+
+	out := make([]VectorItem, 0, len(order))
+	for _, id := range order {
+		out = append(out, *fused[id])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if k < len(out) {
+		out = out[:k]
+	}
+	return out
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLLMQueueFull is returned when too many LLM calls are already waiting
+var ErrLLMQueueFull = errors.New("LLM queue is full")
+
+const (
+	defaultLLMMaxInFlight = 4
+	defaultLLMMaxQueue    = 32
+	// defaultLLMCallDuration seeds Retry-After estimates before any call has finished
+	defaultLLMCallDuration = 30 * time.Second
+)
+
+// QueueFullError is returned by LLMScheduler.Acquire when the queue is full.
+// RetryAfter estimates when a slot is likely to be available again.
+type QueueFullError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", ErrLLMQueueFull, e.RetryAfter.Round(time.Second))
+}
+
+func (e *QueueFullError) Is(target error) bool {
+	return target == ErrLLMQueueFull
+}
+
+// LLMSchedulerStats is a snapshot of the scheduler's queue state
+type LLMSchedulerStats struct {
+	InFlight        int     `json:"in_flight"`
+	MaxInFlight     int     `json:"max_in_flight"`
+	Queued          int     `json:"queued"`
+	MaxQueue        int     `json:"max_queue"`
+	QueuedUsers     int     `json:"queued_users"`
+	Completed       uint64  `json:"completed"`
+	Cancelled       uint64  `json:"cancelled"`
+	Rejected        uint64  `json:"rejected"`
+	AvgCallSeconds  float64 `json:"avg_call_seconds"`
+	LongestWaitSecs float64 `json:"longest_wait_seconds"`
+}
+
+// LLMScheduler bounds the number of concurrent LLM calls. Calls beyond the
+// in-flight limit wait in per-user FIFO queues that are served round-robin,
+// so one user generating a large quiz cannot starve everyone else. Once
+// maxQueue calls are waiting, new calls are rejected with a QueueFullError.
+type LLMScheduler struct {
+	mu          sync.Mutex
+	maxInFlight int
+	maxQueue    int
+	inFlight    int
+	queued      int
+	queues      map[string][]*llmWaiter
+	// order lists users with waiting calls, in round-robin order
+	order []string
+
+	completed   uint64
+	cancelled   uint64
+	rejected    uint64
+	avgDuration time.Duration
+}
+
+type llmWaiter struct {
+	ready    chan struct{}
+	queuedAt time.Time
+}
+
+// NewLLMScheduler creates a scheduler; non-positive limits fall back to defaults
+func NewLLMScheduler(maxInFlight, maxQueue int) *LLMScheduler {
+	if maxInFlight <= 0 {
+		maxInFlight = defaultLLMMaxInFlight
+	}
+	if maxQueue < 0 {
+		maxQueue = defaultLLMMaxQueue
+	}
+	return &LLMScheduler{
+		maxInFlight: maxInFlight,
+		maxQueue:    maxQueue,
+		queues:      make(map[string][]*llmWaiter),
+	}
+}
+
+// Acquire waits for an LLM call slot on behalf of userID. The returned
+// release function must be called exactly once when the call finishes.
+func (s *LLMScheduler) Acquire(ctx context.Context, userID string) (func(), error) {
+	s.mu.Lock()
+
+	if s.inFlight < s.maxInFlight && s.queued == 0 {
+		s.inFlight++
+		s.mu.Unlock()
+		return s.releaseFunc(time.Now()), nil
+	}
+
+	if s.queued >= s.maxQueue {
+		s.rejected++
+		retryAfter := s.estimateWaitLocked(s.queued)
+		s.mu.Unlock()
+		return nil, &QueueFullError{RetryAfter: retryAfter}
+	}
+
+	w := &llmWaiter{ready: make(chan struct{}), queuedAt: time.Now()}
+	if len(s.queues[userID]) == 0 {
+		s.order = append(s.order, userID)
+	}
+	s.queues[userID] = append(s.queues[userID], w)
+	s.queued++
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaseFunc(time.Now()), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cancelled++
+		if !s.removeWaiterLocked(userID, w) {
+			// The slot was granted while we gave up; hand it on without
+			// counting a call that never ran
+			s.inFlight--
+			s.dispatchLocked()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Do runs fn while holding an LLM call slot for userID
+func (s *LLMScheduler) Do(ctx context.Context, userID string, fn func() error) error {
+	release, err := s.Acquire(ctx, userID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// Stats returns a snapshot of the queue state
+func (s *LLMScheduler) Stats() LLMSchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var longest time.Duration
+	now := time.Now()
+	for _, waiters := range s.queues {
+		if len(waiters) > 0 {
+			if wait := now.Sub(waiters[0].queuedAt); wait > longest {
+				longest = wait
+			}
+		}
+	}
+
+	return LLMSchedulerStats{
+		InFlight:        s.inFlight,
+		MaxInFlight:     s.maxInFlight,
+		Queued:          s.queued,
+		MaxQueue:        s.maxQueue,
+		QueuedUsers:     len(s.order),
+		Completed:       s.completed,
+		Cancelled:       s.cancelled,
+		Rejected:        s.rejected,
+		AvgCallSeconds:  s.avgDuration.Seconds(),
+		LongestWaitSecs: longest.Seconds(),
+	}
+}
+
+func (s *LLMScheduler) releaseFunc(start time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.inFlight--
+			s.completed++
+			s.recordDurationLocked(time.Since(start))
+			s.dispatchLocked()
+		})
+	}
+}
+
+// dispatchLocked hands free slots to waiting users in round-robin order
+func (s *LLMScheduler) dispatchLocked() {
+	for s.inFlight < s.maxInFlight && len(s.order) > 0 {
+		userID := s.order[0]
+		s.order = s.order[1:]
+
+		waiters := s.queues[userID]
+		w := waiters[0]
+		if len(waiters) > 1 {
+			s.queues[userID] = waiters[1:]
+			// Back of the line until every other waiting user had a turn
+			s.order = append(s.order, userID)
+		} else {
+			delete(s.queues, userID)
+		}
+
+		s.queued--
+		s.inFlight++
+		close(w.ready)
+	}
+}
+
+// removeWaiterLocked drops w from the queue, reporting false if it was already granted
+func (s *LLMScheduler) removeWaiterLocked(userID string, w *llmWaiter) bool {
+	waiters := s.queues[userID]
+	for i, candidate := range waiters {
+		if candidate != w {
+			continue
+		}
+		waiters = append(waiters[:i], waiters[i+1:]...)
+		s.queued--
+		if len(waiters) > 0 {
+			s.queues[userID] = waiters
+			return true
+		}
+		delete(s.queues, userID)
+		for j, id := range s.order {
+			if id == userID {
+				s.order = append(s.order[:j], s.order[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// recordDurationLocked keeps an exponentially weighted average of call durations
+func (s *LLMScheduler) recordDurationLocked(d time.Duration) {
+	if s.avgDuration == 0 {
+		s.avgDuration = d
+		return
+	}
+	s.avgDuration = (s.avgDuration*4 + d) / 5
+}
+
+// estimateWaitLocked guesses how long a call behind position others would wait
+func (s *LLMScheduler) estimateWaitLocked(position int) time.Duration {
+	avg := s.avgDuration
+	if avg == 0 {
+		avg = defaultLLMCallDuration
+	}
+	rounds := position/s.maxInFlight + 1
+	wait := time.Duration(rounds) * avg
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
@@ -0,0 +1,51 @@
+package services
+
+import "math"
+
+// defaultMMRLambda leans towards relevance while still skipping chunks that
+// mostly repeat one already selected
+const defaultMMRLambda = 0.7
+
+// mmrSelect picks k items from candidates (best first) by maximal marginal
+// relevance: each pick maximizes lambda*relevance - (1-lambda)*redundancy,
+// where relevance is the item's Score scaled to the best candidate and
+// redundancy is its highest cosine similarity to an item already picked.
+// Lambda 1 keeps the original order; lower values favour diversity.
+func mmrSelect(candidates []VectorItem, k int, lambda float64) []VectorItem {
+	var maxScore float64
+	for _, c := range candidates {
+		maxScore = math.Max(maxScore, c.Score)
+	}
+
+	remaining := append([]VectorItem(nil), candidates...)
+	// redundancy[i] is remaining[i]'s highest similarity to the picks so far
+	redundancy := make([]float64, len(remaining))
+	selected := make([]VectorItem, 0, k)
+
+	for len(selected) < k && len(remaining) > 0 {
+		best, bestScore := 0, math.Inf(-1)
+		for i, c := range remaining {
+			relevance := 0.0
+			if maxScore > 0 {
+				relevance = c.Score / maxScore
+			}
+			if score := lambda*relevance - (1-lambda)*redundancy[i]; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		pick := remaining[best]
+		selected = append(selected, pick)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		redundancy = append(redundancy[:best], redundancy[best+1:]...)
+
+		for i, c := range remaining {
+			// Embeddings from different providers cannot be compared
+			if len(c.Embedding) != len(pick.Embedding) {
+				continue
+			}
+			redundancy[i] = math.Max(redundancy[i], cosine(c.Embedding, pick.Embedding))
+		}
+	}
+	return selected
+}
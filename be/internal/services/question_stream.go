@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// rawQuestion is a question as the LLM writes it in the JSON array
+type rawQuestion struct {
+	Question      string   `json:"question"`
+	Options       []string `json:"options"`
+	CorrectAnswer int      `json:"correctAnswer"`
+	Explanation   string   `json:"explanation"`
+}
+
+// toQuestion converts a raw question, reporting false if it is malformed
+func (rq rawQuestion) toQuestion() (models.Question, bool) {
+	if rq.Question == "" || len(rq.Options) != 4 {
+		return models.Question{}, false
+	}
+	return models.Question{
+		ID:            uuid.New().String(),
+		Text:          rq.Question, // Use Text field for database
+		Question:      rq.Question, // Keep Question for backward compatibility
+		Options:       rq.Options,
+		CorrectAnswer: rq.CorrectAnswer,
+		Explanation:   rq.Explanation,
+	}, true
+}
+
+// questionStreamParser pulls complete question objects out of a JSON array
+// that arrives in arbitrary pieces. It tracks brace depth and string state
+// so each top-level object is decoded as soon as its closing brace arrives.
+// Anything before the opening bracket or after the closing one (markdown
+// fences, chatter) is ignored.
+type questionStreamParser struct {
+	buf     []byte
+	pos     int
+	inArray bool
+	// done is set once the array is closed
+	done     bool
+	depth    int
+	inString bool
+	escaped  bool
+	objStart int
+	// skipped counts complete objects that were not valid questions
+	skipped int
+}
+
+// Feed adds text and returns the questions completed by it
+func (p *questionStreamParser) Feed(text string) []models.Question {
+	if p.done {
+		return nil
+	}
+	p.buf = append(p.buf, text...)
+
+	var questions []models.Question
+	for ; p.pos < len(p.buf); p.pos++ {
+		ch := p.buf[p.pos]
+
+		if !p.inArray {
+			if ch == '[' {
+				p.inArray = true
+			}
+			continue
+		}
+
+		if p.inString {
+			switch {
+			case p.escaped:
+				p.escaped = false
+			case ch == '\\':
+				p.escaped = true
+			case ch == '"':
+				p.inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			p.inString = true
+		case ']':
+			if p.depth == 0 {
+				p.done = true
+				p.buf, p.pos = nil, 0
+				return questions
+			}
+		case '{':
+			if p.depth == 0 {
+				p.objStart = p.pos
+			}
+			p.depth++
+		case '}':
+			if p.depth == 0 {
+				continue
+			}
+			p.depth--
+			if p.depth == 0 {
+				var rq rawQuestion
+				if err := json.Unmarshal(p.buf[p.objStart:p.pos+1], &rq); err != nil {
+					p.skipped++
+					continue
+				}
+				if q, ok := rq.toQuestion(); ok {
+					questions = append(questions, q)
+				} else {
+					p.skipped++
+				}
+			}
+		}
+	}
+
+	// Drop consumed input between objects so the buffer stays small
+	if p.depth == 0 && p.inArray {
+		p.buf = p.buf[:0]
+		p.pos = 0
+	}
+	return questions
+}
@@ -24,7 +24,7 @@ func (qs *QuizService) QuizTitleExists(ctx context.Context, title string, userID
 	return qs.repo.QuizTitleExists(ctx, title, userID)
 }
 
-func (qs *QuizService) CreateQuiz(quiz *models.Quiz, userID string) error {
+func (qs *QuizService) CreateQuiz(ctx context.Context, quiz *models.Quiz, userID string) error {
 	if quiz.ID == "" {
 		quiz.ID = uuid.New().String()
 	}
@@ -34,7 +34,6 @@ func (qs *QuizService) CreateQuiz(quiz *models.Quiz, userID string) error {
 	quiz.TotalQuestions = len(quiz.Questions)
 
 	// Try to save to database
-	ctx := context.Background()
 	err := qs.repo.CreateQuiz(ctx, quiz, userID)
 	if err != nil {
 		return fmt.Errorf("failed to create quiz: %w", err)
@@ -43,8 +42,7 @@ func (qs *QuizService) CreateQuiz(quiz *models.Quiz, userID string) error {
 	return nil
 }
 
-func (qs *QuizService) GetQuiz(id string) (*models.Quiz, error) {
-	ctx := context.Background()
+func (qs *QuizService) GetQuiz(ctx context.Context, id string) (*models.Quiz, error) {
 	quiz, err := qs.repo.GetQuiz(ctx, id)
 	if err != nil {
 		return nil, err
@@ -52,8 +50,7 @@ func (qs *QuizService) GetQuiz(id string) (*models.Quiz, error) {
 	return quiz, nil
 }
 
-func (qs *QuizService) GetAllQuizzes(userID string) ([]*models.Quiz, error) {
-	ctx := context.Background()
+func (qs *QuizService) GetAllQuizzes(ctx context.Context, userID string) ([]*models.Quiz, error) {
 	quizzes, err := qs.repo.GetAllQuizzes(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -61,14 +58,13 @@ func (qs *QuizService) GetAllQuizzes(userID string) ([]*models.Quiz, error) {
 	return quizzes, nil
 }
 
-func (qs *QuizService) UpdateQuiz(id string, updates *models.Quiz) error {
+func (qs *QuizService) UpdateQuiz(ctx context.Context, id string, updates *models.Quiz) error {
 	// For now, updating is not implemented in DB layer
 	// This would require more complex logic
 	return fmt.Errorf("update not implemented yet")
 }
 
-func (qs *QuizService) DeleteQuiz(id string) error {
-	ctx := context.Background()
+func (qs *QuizService) DeleteQuiz(ctx context.Context, id string) error {
 	err := qs.repo.DeleteQuiz(ctx, id)
 	if err != nil {
 		return err
@@ -76,8 +72,7 @@ func (qs *QuizService) DeleteQuiz(id string) error {
 	return nil
 }
 
-func (qs *QuizService) GetQuizAttempt(attemptID string) (map[string]interface{}, error) {
-	ctx := context.Background()
+func (qs *QuizService) GetQuizAttempt(ctx context.Context, attemptID string) (map[string]interface{}, error) {
 	attempt, err := qs.repo.GetQuizAttempt(ctx, attemptID)
 	if err != nil {
 		return nil, err
@@ -85,11 +80,23 @@ func (qs *QuizService) GetQuizAttempt(attemptID string) (map[string]interface{},
 	return attempt, nil
 }
 
-func (qs *QuizService) ListUserAttempts(userID string) ([]map[string]interface{}, error) {
-	ctx := context.Background()
+func (qs *QuizService) GetAttempt(ctx context.Context, attemptID string) (*models.QuizAttempt, error) {
+	return qs.repo.GetAttempt(ctx, attemptID)
+}
+
+func (qs *QuizService) ListUserAttempts(ctx context.Context, userID string) ([]map[string]interface{}, error) {
 	attempts, err := qs.repo.ListUserAttempts(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	return attempts, nil
 }
+
+// DocumentInUse reports whether any quiz still references the document
+func (qs *QuizService) DocumentInUse(ctx context.Context, documentID string) (bool, error) {
+	count, err := qs.repo.CountQuizzesForDocument(ctx, documentID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
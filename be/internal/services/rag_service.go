@@ -1,160 +1,184 @@
-	package services
+package services
 
 import (
+	"context"
 	"crypto/sha1"
 	"math"
-	"sort"
-	"strings"
+
+	"pbkk-quizlit-backend/internal/models"
 )
 
 // EmbeddingProvider provides text embeddings for retrieval
 type EmbeddingProvider interface {
 	// Embed returns a vector embedding for the given text
-	Embed(text string) ([]float64, error)
+	Embed(ctx context.Context, text string) ([]float64, error)
 }
 
 // RAGService offers chunking, embedding, and retrieval over PDF/text content
 type RAGService struct {
 	embedder EmbeddingProvider
-	store    *VectorStore
+	store    VectorStore
 	// configuration
 	chunkSize    int
 	chunkOverlap int
+	// lexicalWeight is the share of BM25 in hybrid retrieval; 0 is vector only
+	lexicalWeight float64
 }
 
-// NewRAGService constructs a RAG service with the given embedder
+// NewRAGService constructs a RAG service with the given embedder and an in-memory store
 func NewRAGService(embedder EmbeddingProvider) *RAGService {
+	return NewRAGServiceWithStore(embedder, NewMemoryVectorStore())
+}
+
+// NewRAGServiceWithStore constructs a RAG service backed by the given vector store
+func NewRAGServiceWithStore(embedder EmbeddingProvider, store VectorStore) *RAGService {
 	return &RAGService{
-		embedder:     embedder,
-		store:        NewVectorStore(),
-		chunkSize:    800, // characters per chunk
-		chunkOverlap: 150, // overlap between chunks
+		embedder:      embedder,
+		store:         store,
+		chunkSize:     800, // runes per chunk
+		chunkOverlap:  150, // runes shared by consecutive chunks
+		lexicalWeight: defaultLexicalWeight,
 	}
 }
 
-// BuildIndex tokenizes content into chunks, embeds them, and stores in memory
-func (r *RAGService) BuildIndex(docID string, content string) error {
-	chunks := r.chunkText(content)
-	for i, ch := range chunks {
-		// compute embedding
-		emb, err := r.embedder.Embed(ch)
-		if err != nil {
-			return err
-		}
-		// use stable id
-		chunkID := docID + ":" + itoa(i)
-		r.store.Upsert(VectorItem{ID: chunkID, Text: ch, Embedding: emb})
+// SetLexicalWeight sets the share of keyword (BM25) ranking in hybrid
+// retrieval, from 0 (vector similarity only) to 1 (keywords only). Any
+// weight above 0 costs a BM25 pass per query: over every chunk of the
+// documents in the filter when it names at most maxLexicalScanDocuments,
+// and otherwise over the lexicalPoolFactor times wider vector candidates.
+func (r *RAGService) SetLexicalWeight(weight float64) {
+	r.lexicalWeight = math.Max(0, math.Min(1, weight))
+}
+
+// SetChunking sets the chunk size and the overlap between consecutive
+// chunks, both in runes. Non-positive sizes keep the current setting.
+func (r *RAGService) SetChunking(size, overlap int) {
+	if size > 0 {
+		r.chunkSize = size
+	}
+	if overlap >= 0 && overlap < r.chunkSize {
+		r.chunkOverlap = overlap
 	}
-	return nil
 }
 
-// Retrieve returns topK most similar chunks given a query
-func (r *RAGService) Retrieve(query string, topK int) ([]VectorItem, error) {
-	if topK <= 0 {
-		topK = 5
+// BuildIndex splits the document's pages into chunks along page, heading and
+// paragraph boundaries, embeds them, and atomically replaces the document's
+// chunks in the vector store under the user's namespace
+func (r *RAGService) BuildIndex(ctx context.Context, userID, docID string, pages []models.DocumentPage) error {
+	chunks := chunkPages(pages, r.chunkSize, r.chunkOverlap)
+	texts := make([]string, len(chunks))
+	for i, ch := range chunks {
+		texts[i] = ch.Text
 	}
-	qEmb, err := r.embedder.Embed(query)
+	// compute embeddings, batched when the provider supports it
+	embeddings, err := embedAll(ctx, r.embedder, texts)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return r.store.TopK(qEmb, topK), nil
+	items := make([]VectorItem, len(chunks))
+	for i, ch := range chunks {
+		// use stable id
+		chunkID := docID + ":" + itoa(i)
+		items[i] = VectorItem{
+			ID:         chunkID,
+			DocumentID: docID,
+			UserID:     userID,
+			ChunkIndex: i,
+			Page:       ch.Page,
+			Section:    ch.Section,
+			Text:       ch.Text,
+			Embedding:  embeddings[i],
+		}
+	}
+	// Chunks left over from an earlier, longer version of the document go
+	// in the same step, so concurrent searches never find it empty
+	return r.store.ReplaceDocument(ctx, docID, items)
 }
 
-// chunkText splits text into overlapping chunks suitable for retrieval
-func (r *RAGService) chunkText(text string) []string {
-	cleaned := strings.TrimSpace(text)
-	if cleaned == "" {
-		return []string{}
-	}
-	// simple sentence split with fallbacks
-	delimiters := []string{". ", "\n", "? ", "! "}
-	parts := []string{cleaned}
-	for _, d := range delimiters {
-		var next []string
-		for _, p := range parts {
-			next = append(next, strings.Split(p, d)...)
-		}
-		parts = next
-	}
-	// rebuild chunks up to chunkSize
-	var chunks []string
-	var buf strings.Builder
-	for _, s := range parts {
-		s = strings.TrimSpace(s)
-		if s == "" {
-			continue
-		}
-		if buf.Len()+len(s)+1 > r.chunkSize {
-			// flush current chunk
-			ch := strings.TrimSpace(buf.String())
-			if len(ch) > 0 {
-				chunks = append(chunks, ch)
-			}
-			// start new buffer with overlap from previous chunk
-			if r.chunkOverlap > 0 && len(ch) > r.chunkOverlap {
-				overlap := ch[len(ch)-r.chunkOverlap:]
-				buf.Reset()
-				buf.WriteString(overlap)
-				buf.WriteString(" ")
-			} else {
-				buf.Reset()
-			}
-		}
-		if buf.Len() > 0 {
-			buf.WriteString(" ")
-		}
-		buf.WriteString(s)
+// EnsureIndexed indexes a document unless its chunks are already stored
+func (r *RAGService) EnsureIndexed(ctx context.Context, userID, docID string, pages []models.DocumentPage) error {
+	items, err := r.store.List(ctx, VectorFilter{DocumentIDs: []string{docID}})
+	if err != nil {
+		return err
 	}
-	// final flush
-	if buf.Len() > 0 {
-		chunks = append(chunks, strings.TrimSpace(buf.String()))
+	if len(items) > 0 {
+		return nil
 	}
-	return chunks
+	return r.BuildIndex(ctx, userID, docID, pages)
 }
 
-// VectorItem is a single chunk with its embedding
-type VectorItem struct {
-	ID        string
-	Text      string
-	Embedding []float64
+// DeleteDocument removes a document's chunks from the index
+func (r *RAGService) DeleteDocument(ctx context.Context, docID string) error {
+	return r.store.DeleteDocument(ctx, docID)
 }
 
-// VectorStore is a minimal in-memory store for embeddings
-type VectorStore struct {
-	items []VectorItem
+// RetrieveOptions controls a retrieval
+type RetrieveOptions struct {
+	// Filter restricts results to a user's documents
+	Filter VectorFilter
+	// MMRLambda re-ranks results by maximal marginal relevance when between
+	// 0 and 1: 1 is pure relevance, lower values trade relevance for chunks
+	// that cover different parts of the document. 0 disables re-ranking.
+	MMRLambda float64
 }
 
-func NewVectorStore() *VectorStore { return &VectorStore{items: []VectorItem{}} }
-
-func (vs *VectorStore) Upsert(item VectorItem) {
-	for i := range vs.items {
-		if vs.items[i].ID == item.ID {
-			vs.items[i] = item
-			return
-		}
+// Retrieve returns the topK chunks matching opts.Filter that best answer
+// query. Vector similarity and BM25 keyword rankings are combined with
+// reciprocal rank fusion so exact technical terms are not lost to the
+// embedding; each result carries its fused, vector and lexical scores.
+// With MMR enabled the results are picked from a wider pool so that
+// near-duplicate chunks give way to distinct ones.
+func (r *RAGService) Retrieve(ctx context.Context, query string, topK int, opts RetrieveOptions) ([]VectorItem, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	qEmb, err := r.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, err
 	}
-	vs.items = append(vs.items, item)
-}
 
-// TopK returns top-k items by cosine similarity
-func (vs *VectorStore) TopK(query []float64, k int) []VectorItem {
-	type scored struct {
-		item  VectorItem
-		score float64
+	useMMR := opts.MMRLambda > 0 && opts.MMRLambda < 1
+	// Rank a wider pool so fusion and MMR have something to work with
+	pool := max(topK*4, 20)
+	if !useMMR && r.lexicalWeight <= 0 {
+		pool = topK
 	}
-	var arr []scored
-	for _, it := range vs.items {
-		arr = append(arr, scored{item: it, score: cosine(query, it.Embedding)})
+
+	var items []VectorItem
+	if r.lexicalWeight <= 0 {
+		items, err = r.store.TopK(ctx, qEmb, pool, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].VectorScore = items[i].Score
+		}
+	} else {
+		// Keywords are ranked over the whole of a few named documents, and
+		// otherwise over a wider pool of vector candidates, so a query across
+		// the library never lists every chunk
+		candidates, err := r.store.TopK(ctx, qEmb, pool*lexicalPoolFactor, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		vector := candidates[:min(pool, len(candidates))]
+		if n := len(opts.Filter.DocumentIDs); n > 0 && n <= maxLexicalScanDocuments {
+			candidates, err = r.store.List(ctx, opts.Filter)
+			if err != nil {
+				return nil, err
+			}
+		}
+		lexical := lexicalTopK(query, candidates, pool)
+		items = fuseRankings(vector, lexical, r.lexicalWeight, pool)
 	}
-	sort.Slice(arr, func(i, j int) bool { return arr[i].score > arr[j].score })
-	if k > len(arr) {
-		k = len(arr)
+
+	if useMMR {
+		return mmrSelect(items, topK, opts.MMRLambda), nil
 	}
-	out := make([]VectorItem, 0, k)
-	for i := 0; i < k; i++ {
-		out = append(out, arr[i].item)
+	if len(items) > topK {
+		items = items[:topK]
 	}
-	return out
+	return items, nil
 }
 
 func cosine(a, b []float64) float64 {
@@ -180,7 +204,7 @@ func cosine(a, b []float64) float64 {
 // replaced by a proper embedding model in production.
 type HashEmbedding struct{}
 
-func (h HashEmbedding) Embed(text string) ([]float64, error) {
+func (h HashEmbedding) Embed(_ context.Context, text string) ([]float64, error) {
 	sum := sha1.Sum([]byte(text))
 	// Expand to 32-dim vector deterministically
 	vec := make([]float64, 32)
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// maxRemedialQuestions caps the size of a practice quiz
+const maxRemedialQuestions = 15
+
+// GenerateRemedialQuiz builds a practice quiz covering the concepts behind
+// the questions a user answered incorrectly. Source chunks for each missed
+// question are retrieved through RAG so the new questions stay grounded in
+// the original material. doc may be nil when the source document is no
+// longer available.
+func (ai *AIService) GenerateRemedialQuiz(ctx context.Context, source *models.Quiz, missed []models.Question, doc *models.Document) (*models.Quiz, error) {
+	if len(missed) == 0 {
+		return nil, fmt.Errorf("no incorrectly answered questions to practice")
+	}
+	if !ai.useSenopati {
+		return nil, fmt.Errorf("no AI provider configured - Senopati is required")
+	}
+
+	count := len(missed)
+	if count < 3 {
+		count = 3
+	}
+	if count > maxRemedialQuestions {
+		count = maxRemedialQuestions
+	}
+
+	ai.logger.Infof("Generating remedial quiz with %d questions for %d missed questions", count, len(missed))
+
+	content := ai.retrieveRemedialContext(ctx, missed, doc)
+
+	model := ai.selectSenopatiModel(ctx)
+	resp, err := ai.generateText(ctx, source.UserID, model, ai.buildRemedialPrompt(content, missed, count), 0.8, completionTokenBudget(count))
+	if err != nil {
+		return nil, fmt.Errorf("senopati API error: %w", err)
+	}
+
+	generated, err := ai.parseAIResponse(resp.Response)
+	if err != nil {
+		ai.logger.Errorf("Failed to parse remedial quiz response: %v", err)
+		return nil, err
+	}
+
+	// The model sometimes echoes the original questions; keep only new ones
+	var questions []models.Question
+	for _, q := range generated {
+		if isNearDuplicateQuestion(q.Text, missed) {
+			ai.logger.Warnf("Dropping remedial question that copies an original: %s", q.Text)
+			continue
+		}
+		questions = append(questions, q)
+		if len(questions) >= count {
+			break
+		}
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("no new questions were generated")
+	}
+
+	quiz := &models.Quiz{
+		ID:             uuid.New().String(),
+		DocumentID:     source.DocumentID,
+		Filename:       source.Filename,
+		ParentQuizID:   source.ID,
+		Title:          fmt.Sprintf("Practice: %s", source.Title),
+		Description:    fmt.Sprintf("Practice quiz on the %d questions missed in '%s'", len(missed), source.Title),
+		Questions:      questions,
+		Difficulty:     source.Difficulty,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		TotalQuestions: len(questions),
+	}
+
+	ai.logger.Infof("Successfully generated remedial quiz with %d questions", len(questions))
+	return quiz, nil
+}
+
+// retrieveRemedialContext collects the source chunks behind each missed question
+// Retrieval is limited to the quiz's own document; without it there is
+// nothing safe to search.
+func (ai *AIService) retrieveRemedialContext(ctx context.Context, missed []models.Question, doc *models.Document) string {
+	if ai.rag != nil && ai.enableRAG && doc != nil {
+		// Make sure the document is indexed, keeping the chunks it has
+		if err := ai.rag.EnsureIndexed(ctx, doc.UserID, doc.ID, documentPages(doc)); err != nil {
+			ai.logger.Warnf("RAG indexing failed: %v", err)
+		}
+		filter := VectorFilter{UserID: doc.UserID, DocumentIDs: []string{doc.ID}}
+
+		var b strings.Builder
+		seen := make(map[string]bool)
+		maxTotalLength := 8000
+
+		for _, q := range missed {
+			query := strings.TrimSpace(q.Text + " " + correctOptionText(q))
+			chunks, err := ai.rag.Retrieve(ctx, query, 3, RetrieveOptions{Filter: filter, MMRLambda: ai.mmrLambda})
+			if err != nil {
+				ai.logger.Warnf("RAG retrieval failed for question %s: %v", q.ID, err)
+				continue
+			}
+			for _, ch := range chunks {
+				if seen[ch.ID] {
+					continue
+				}
+				text := strings.TrimSpace(ch.Text)
+				if b.Len()+len(text) > maxTotalLength {
+					break
+				}
+				seen[ch.ID] = true
+				if b.Len() > 0 {
+					b.WriteString("\n\n")
+				}
+				b.WriteString(text)
+			}
+		}
+
+		if b.Len() > 0 {
+			ai.logger.Infof("RAG selected %d source chunks for remedial quiz", len(seen))
+			return b.String()
+		}
+	}
+
+	// Fall back to the whole document, then to the questions themselves
+	if doc != nil && strings.TrimSpace(doc.Content) != "" {
+		content := doc.Content
+		if len(content) > maxSenopatiContentLength {
+			content = content[:maxSenopatiContentLength]
+		}
+		return content
+	}
+
+	var b strings.Builder
+	for _, q := range missed {
+		b.WriteString(q.Text)
+		b.WriteString(" ")
+		b.WriteString(correctOptionText(q))
+		if q.Explanation != "" {
+			b.WriteString(". ")
+			b.WriteString(q.Explanation)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (ai *AIService) buildRemedialPrompt(content string, missed []models.Question, count int) string {
+	var concepts strings.Builder
+	for i, q := range missed {
+		fmt.Fprintf(&concepts, "%d. Question: %s\n   Correct answer: %s\n", i+1, q.Text, correctOptionText(q))
+	}
+
+	return fmt.Sprintf(`A student answered the following questions incorrectly:
+
+%s
+Source material:
+%s
+
+Create a practice quiz with EXACTLY %d NEW multiple-choice questions that test the SAME concepts as the questions above.
+
+Requirements:
+- Do NOT copy or lightly reword the questions above; ask about the concept from a different angle (definition, application, example, comparison)
+- Base every question on the source material
+- Each question must have exactly 4 answer options with only one correct answer
+- Indicate the correct answer as index (0-3)
+- Write an explanation that helps the student understand the concept they missed
+- LANGUAGE: Generate ALL questions and options in Bahasa Indonesia ONLY
+
+Format as JSON ARRAY with this EXACT structure:
+[
+  {
+    "question": "What is the complete question text here?",
+    "options": ["Option A text", "Option B text", "Option C text", "Option D text"],
+    "correctAnswer": 0,
+    "explanation": "Brief explanation"
+  }
+]
+
+Return ONLY valid JSON array, no markdown formatting.`, concepts.String(), content, count)
+}
+
+// correctOptionText returns the text of a question's correct option
+func correctOptionText(q models.Question) string {
+	if q.CorrectAnswer >= 0 && q.CorrectAnswer < len(q.Options) {
+		return q.Options[q.CorrectAnswer]
+	}
+	return q.Correct
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"pbkk-quizlit-backend/internal/extract"
+	"pbkk-quizlit-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// QuizSection is a part of a document that gets its own share of a quiz's
+// questions
+type QuizSection struct {
+	Title string
+	Pages []models.DocumentPage
+}
+
+// SplitSections divides the selected pages of a document into the parts a
+// quiz per section is generated from: the chapters of an e-book, or for a
+// PDF the outline sections numbered in sectionsSpec, by default the top
+// level of its outline. A page goes to the most specific section holding
+// it; pages outside every section form a part of their own.
+func SplitSections(doc *models.Document, pages []models.DocumentPage, sectionsSpec string) ([]QuizSection, error) {
+	if ListChapters(pages) != nil {
+		parts := make([]QuizSection, len(pages))
+		for i, page := range pages {
+			parts[i] = QuizSection{Title: page.Title, Pages: []models.DocumentPage{page}}
+		}
+		return parts, nil
+	}
+
+	outline := extract.FlattenSections(doc.Sections)
+	if len(outline) == 0 {
+		return nil, fmt.Errorf("quizzes per section need a PDF with an outline or an e-book with chapters")
+	}
+
+	var picked []models.DocumentSection
+	if strings.TrimSpace(sectionsSpec) != "" {
+		selected, err := parseRanges(sectionsSpec, len(outline))
+		if err != nil {
+			return nil, fmt.Errorf("invalid sections %q: %w", sectionsSpec, err)
+		}
+		for _, section := range outline {
+			if selected[section.Number] {
+				picked = append(picked, section)
+			}
+		}
+	} else {
+		// A single top-level entry is usually the document's own title
+		top := doc.Sections
+		for len(top) == 1 && len(top[0].Children) > 0 {
+			top = top[0].Children
+		}
+		for _, section := range top {
+			section.Children = nil
+			picked = append(picked, section)
+		}
+	}
+
+	var parts []QuizSection
+	// index maps a section number to its part; 0 is the part of pages outside every section
+	index := make(map[int]int)
+	for _, page := range pages {
+		best := -1
+		for i, section := range picked {
+			if page.Number >= section.StartPage && page.Number <= section.EndPage &&
+				(best < 0 || section.StartPage >= picked[best].StartPage) {
+				best = i
+			}
+		}
+		number, title := 0, doc.Title
+		if best >= 0 {
+			number, title = picked[best].Number, picked[best].Title
+		}
+		i, ok := index[number]
+		if !ok {
+			i = len(parts)
+			index[number] = i
+			parts = append(parts, QuizSection{Title: title})
+		}
+		parts[i].Pages = append(parts[i].Pages, page)
+	}
+	return parts, nil
+}
+
+const (
+	// maxQuizSections caps the parts of a quiz per section; beyond it the
+	// shortest neighbouring sections are merged
+	maxQuizSections = 10
+	// sectionConcurrency is the number of sections generated at once
+	sectionConcurrency = 3
+)
+
+// GenerateQuizBySection generates one quiz from several sections, giving
+// each section a share of the questions in proportion to its length but at
+// least MinQuestionCount. Sections too short for that, or beyond
+// maxQuizSections, are merged with a neighbour. Sections are generated
+// concurrently, and questions repeating another section's are dropped.
+// Every question records the section it was generated from in its metadata.
+func (ai *AIService) GenerateQuizBySection(ctx context.Context, sections []QuizSection, req *models.QuizGenerationRequest) (*models.Quiz, error) {
+	if req.QuestionCount == 0 {
+		req.QuestionCount = 10
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no sections to generate questions from")
+	}
+
+	weights := make([]int, len(sections))
+	for i, section := range sections {
+		weights[i] = len(strings.Fields(PagesText(section.Pages)))
+	}
+	sections, weights = mergeSections(sections, weights, max(1, min(maxQuizSections, req.QuestionCount/MinQuestionCount)))
+
+	// Every section gets the minimum first; the rest follows section length
+	minimum := MinQuestionCount
+	if req.QuestionCount < minimum*len(sections) {
+		minimum = 0
+	}
+	shares := apportion(req.QuestionCount-minimum*len(sections), weights)
+	for i := range shares {
+		shares[i] += minimum
+	}
+
+	sectionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	results := make([][]models.Question, len(sections))
+	slots := make(chan struct{}, sectionConcurrency)
+	for i, section := range sections {
+		if shares[i] == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, section QuizSection) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if sectionCtx.Err() != nil {
+				return
+			}
+			ai.logger.Infof("Generating %d questions for section %q", shares[i], section.Title)
+
+			sectionReq := *req
+			sectionReq.QuestionCount = shares[i]
+			sectionReq.Pages = section.Pages
+			// Sections are indexed on their own, leaving the document's index intact
+			sectionReq.DocumentID = ""
+			quiz, err := ai.GenerateQuizFromContent(sectionCtx, PagesText(section.Pages), &sectionReq)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("section %q: %w", section.Title, err)
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = quiz.Questions
+		}(i, section)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	var questions []models.Question
+	for i, section := range sections {
+		for _, question := range results[i] {
+			// Sections are generated apart, so overlapping material can
+			// yield the same question twice
+			if isNearDuplicateQuestion(question.Text, questions) {
+				ai.logger.Infof("Dropping question repeated in section %q: %s", section.Title, question.Text)
+				continue
+			}
+			if question.Metadata == nil {
+				question.Metadata = make(map[string]interface{})
+			}
+			question.Metadata["section"] = section.Title
+			questions = append(questions, question)
+		}
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("quiz generation failed: no questions generated")
+	}
+
+	return &models.Quiz{
+		ID:             uuid.New().String(),
+		Title:          req.Title,
+		Description:    req.Description,
+		Questions:      questions,
+		Difficulty:     req.Difficulty,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		TotalQuestions: len(questions),
+	}, nil
+}
+
+// mergeSections merges neighbouring sections, shortest pair first, until at
+// most limit remain. Merged sections join their titles with " / ".
+func mergeSections(sections []QuizSection, weights []int, limit int) ([]QuizSection, []int) {
+	sections = append([]QuizSection(nil), sections...)
+	weights = append([]int(nil), weights...)
+	for len(sections) > limit {
+		best := 0
+		for i := 1; i+1 < len(sections); i++ {
+			if weights[i]+weights[i+1] < weights[best]+weights[best+1] {
+				best = i
+			}
+		}
+		sections[best] = QuizSection{
+			Title: sections[best].Title + " / " + sections[best+1].Title,
+			Pages: append(append([]models.DocumentPage(nil), sections[best].Pages...), sections[best+1].Pages...),
+		}
+		weights[best] += weights[best+1]
+		sections = append(sections[:best+1], sections[best+2:]...)
+		weights = append(weights[:best+1], weights[best+2:]...)
+	}
+	return sections, weights
+}
+
+// apportion splits total in proportion to weights by largest remainder, so
+// the shares add up to total
+func apportion(total int, weights []int) []int {
+	shares := make([]int, len(weights))
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 {
+		return shares
+	}
+
+	assigned := 0
+	remainders := make([]int, len(weights))
+	for i, w := range weights {
+		shares[i] = total * w / sum
+		remainders[i] = total * w % sum
+		assigned += shares[i]
+	}
+	for ; assigned < total; assigned++ {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		shares[best]++
+		remainders[best] = -1
+	}
+	return shares
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pbkk-quizlit-backend/internal/extract"
+	"pbkk-quizlit-backend/internal/models"
+)
+
+// ListChapters returns the chapters of an e-book from its pages, or nil when
+// the document is not divided into chapters
+func ListChapters(pages []models.DocumentPage) []models.DocumentChapter {
+	var chapters []models.DocumentChapter
+	for _, page := range pages {
+		if page.Title == "" {
+			return nil
+		}
+		chapters = append(chapters, models.DocumentChapter{
+			Number: page.Number,
+			Title:  page.Title,
+			Words:  len(strings.Fields(page.Text)),
+		})
+	}
+	return chapters
+}
+
+// SelectChapters keeps the e-book chapters listed in spec, a comma-separated
+// list of chapter numbers and ranges such as "1,3-5"
+func SelectChapters(pages []models.DocumentPage, spec string) ([]models.DocumentPage, error) {
+	chapters := ListChapters(pages)
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("chapters can only be selected for e-book uploads")
+	}
+	selected, err := parseRanges(spec, chapters[len(chapters)-1].Number)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chapters %q: %w", spec, err)
+	}
+
+	var kept []models.DocumentPage
+	for _, page := range pages {
+		if selected[page.Number] {
+			kept = append(kept, page)
+		}
+	}
+	return kept, nil
+}
+
+// SelectPages keeps the pages of a PDF document in the pages spec, page
+// ranges such as "40-55", together with the pages of the outline sections
+// numbered in the sections spec. It returns the selection so it can be
+// recorded on the quiz generated from it.
+func SelectPages(doc *models.Document, pages, sections string) ([]models.DocumentPage, *models.PageSelection, error) {
+	if doc.ContentType != extract.MIMEPDF {
+		return nil, nil, fmt.Errorf("pages and sections can only be selected for PDF documents")
+	}
+
+	keep := make(map[int]bool)
+	selection := &models.PageSelection{}
+	if strings.TrimSpace(pages) != "" {
+		selected, err := parseRanges(pages, doc.PageCount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid pages %q: %w", pages, err)
+		}
+		for n := range selected {
+			keep[n] = true
+		}
+	}
+	if strings.TrimSpace(sections) != "" {
+		outline := extract.FlattenSections(doc.Sections)
+		if len(outline) == 0 {
+			return nil, nil, fmt.Errorf("sections can only be selected for PDFs with an outline")
+		}
+		selected, err := parseRanges(sections, len(outline))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid sections %q: %w", sections, err)
+		}
+		for _, section := range outline {
+			if !selected[section.Number] {
+				continue
+			}
+			selection.Sections = append(selection.Sections, section.Title)
+			for n := section.StartPage; n <= section.EndPage; n++ {
+				keep[n] = true
+			}
+		}
+	}
+	selection.Pages = formatRanges(keep)
+
+	var kept []models.DocumentPage
+	for _, page := range doc.Pages {
+		if keep[page.Number] {
+			kept = append(kept, page)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, nil, fmt.Errorf("no text content found on pages %s", selection.Pages)
+	}
+	return kept, selection, nil
+}
+
+// PagesText joins the text of pages into one document
+func PagesText(pages []models.DocumentPage) string {
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = page.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// parseRanges parses a comma-separated list of numbers and ranges such as
+// "1,3-5" into the set of numbers it covers, each between 1 and limit
+func parseRanges(spec string, limit int) (map[int]bool, error) {
+	selected := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to := part, part
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			from, to = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", part)
+		}
+		end, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", part)
+		}
+		if start > end {
+			return nil, fmt.Errorf("%q is not a valid range", part)
+		}
+		if start < 1 || end > limit {
+			return nil, fmt.Errorf("%q is outside 1-%d", part, limit)
+		}
+		for n := start; n <= end; n++ {
+			selected[n] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("nothing selected")
+	}
+	return selected, nil
+}
+
+// formatRanges formats a set of numbers as a comma-separated list of ranges,
+// the inverse of parseRanges
+func formatRanges(set map[int]bool) string {
+	numbers := make([]int, 0, len(set))
+	for n := range set {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var parts []string
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(numbers[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(numbers[i])+"-"+strconv.Itoa(numbers[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -2,35 +2,115 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 )
 
+const (
+	defaultSenopatiMaxRetries       = 3
+	defaultSenopatiRetryBaseDelay   = 500 * time.Millisecond
+	defaultSenopatiRetryMaxDelay    = 10 * time.Second
+	defaultSenopatiBreakerThreshold = 5
+	defaultSenopatiBreakerCooldown  = 30 * time.Second
+	// defaultSenopatiCallBudget bounds a call together with its retries
+	defaultSenopatiCallBudget = 3 * time.Minute
+	// defaultSenopatiStreamIdleTimeout aborts a stream that stops sending chunks
+	defaultSenopatiStreamIdleTimeout = 60 * time.Second
+	// maxSenopatiRetryAfter caps how long an upstream Retry-After can make us wait
+	maxSenopatiRetryAfter = 30 * time.Second
+)
+
 type SenopatiClient struct {
 	BaseURL    string
 	APIKey     string
 	HTTPClient *http.Client
+	// StreamClient has no overall timeout; streams are bounded by StreamIdleTimeout instead
+	StreamClient      *http.Client
+	StreamIdleTimeout time.Duration
+
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// CallBudget bounds the time a non-streaming call may take across all
+	// of its attempts and backoff; 0 leaves only the per-attempt timeout
+	CallBudget time.Duration
+
+	breaker *CircuitBreaker
+}
+
+// SenopatiOptions configures retries, the circuit breaker and the time
+// budget of Senopati calls. Zero durations and a zero breaker threshold
+// pick the defaults; MaxRetries is used as given, and a negative value
+// picks the default.
+type SenopatiOptions struct {
+	MaxRetries       int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	CallBudget       time.Duration
+}
+
+// APIError is returned when Senopati answers with a non-200 status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Body)
 }
 
-// NewSenopatiClient creates a new Senopati API client
+// NewSenopatiClient creates a new Senopati API client with the default
+// retry and circuit breaker settings
 func NewSenopatiClient() *SenopatiClient {
+	return NewSenopatiClientWithOptions(SenopatiOptions{MaxRetries: -1})
+}
+
+// NewSenopatiClientWithOptions creates a Senopati API client with the given
+// retry and circuit breaker settings
+func NewSenopatiClientWithOptions(opts SenopatiOptions) *SenopatiClient {
 	baseURL := os.Getenv("SENOPATI_API_BASE_URL")
 	if baseURL == "" {
 		baseURL = "https://senopati.its.ac.id/senopati-lokal-dev"
 	}
 
-	return &SenopatiClient{
+	client := &SenopatiClient{
 		BaseURL: baseURL,
 		APIKey:  os.Getenv("SENOPATI_API_KEY"),
 		HTTPClient: &http.Client{
 			Timeout: 120 * time.Second,
 		},
+		StreamClient:      &http.Client{},
+		StreamIdleTimeout: defaultSenopatiStreamIdleTimeout,
+		MaxRetries:        opts.MaxRetries,
+		RetryBaseDelay:    orDefault(opts.RetryBaseDelay, defaultSenopatiRetryBaseDelay),
+		RetryMaxDelay:     orDefault(opts.RetryMaxDelay, defaultSenopatiRetryMaxDelay),
+		CallBudget:        orDefault(opts.CallBudget, defaultSenopatiCallBudget),
+		breaker: NewCircuitBreaker(
+			orDefault(opts.BreakerThreshold, defaultSenopatiBreakerThreshold),
+			orDefault(opts.BreakerCooldown, defaultSenopatiBreakerCooldown),
+		),
 	}
+	if opts.MaxRetries < 0 {
+		client.MaxRetries = defaultSenopatiMaxRetries
+	}
+	return client
+}
+
+// Health returns the circuit breaker state for the Senopati upstream
+func (c *SenopatiClient) Health() CircuitHealth {
+	return c.breaker.Health()
 }
 
 // GenerateRequest represents the request body for /generate endpoint
@@ -90,7 +170,7 @@ type ModelsResponse struct {
 }
 
 // GenerateText calls the /generate endpoint
-func (c *SenopatiClient) GenerateText(model, prompt string, temperature float64, maxTokens int) (*GenerateResponse, error) {
+func (c *SenopatiClient) GenerateText(ctx context.Context, model, prompt string, temperature float64, maxTokens int) (*GenerateResponse, error) {
 	reqBody := GenerateRequest{
 		Model:       model,
 		Prompt:      prompt,
@@ -108,28 +188,12 @@ func (c *SenopatiClient) GenerateText(model, prompt string, temperature float64,
 	fmt.Printf("[Senopati] POST %s/generate with model=%s, prompt length=%d, temp=%.1f\n",
 		c.BaseURL, model, len(prompt), temperature)
 
-	req, err := http.NewRequest("POST", c.BaseURL+"/generate", bytes.NewBuffer(jsonData))
+	resp, err := c.do(ctx, "POST", "/generate", "application/json", jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	if c.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.APIKey)
-	}
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("[Senopati] Error response: %s\n", string(body))
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	// Senopati returns an object like Ollama format
 	var result GenerateResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -140,7 +204,7 @@ func (c *SenopatiClient) GenerateText(model, prompt string, temperature float64,
 }
 
 // Chat calls the /chat endpoint
-func (c *SenopatiClient) Chat(model string, messages []ChatMessage, temperature float64, maxTokens int) (*ChatResponse, error) {
+func (c *SenopatiClient) Chat(ctx context.Context, model string, messages []ChatMessage, temperature float64, maxTokens int) (*ChatResponse, error) {
 	reqBody := ChatRequest{
 		Model:       model,
 		Messages:    messages,
@@ -153,27 +217,12 @@ func (c *SenopatiClient) Chat(model string, messages []ChatMessage, temperature
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.BaseURL+"/chat", bytes.NewBuffer(jsonData))
+	resp, err := c.do(ctx, "POST", "/chat", "application/json", jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	if c.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.APIKey)
-	}
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	var result ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -183,7 +232,7 @@ func (c *SenopatiClient) Chat(model string, messages []ChatMessage, temperature
 }
 
 // VisionPDF calls the /vision/pdf endpoint with a PDF file
-func (c *SenopatiClient) VisionPDF(model, prompt string, pdfData []byte) (*VisionPDFResponse, error) {
+func (c *SenopatiClient) VisionPDF(ctx context.Context, model, prompt string, pdfData []byte) (*VisionPDFResponse, error) {
 	// Create multipart form
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -212,29 +261,29 @@ func (c *SenopatiClient) VisionPDF(model, prompt string, pdfData []byte) (*Visio
 		return nil, fmt.Errorf("failed to close writer: %w", err)
 	}
 
-	// Create request
-	req, err := http.NewRequest("POST", c.BaseURL+"/vision/pdf", &buf)
+	resp, err := c.do(ctx, "POST", "/vision/pdf", writer.FormDataContentType(), buf.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	if c.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	var result VisionPDFResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	return &result, nil
+}
+
+// ListModels calls the /models endpoint
+func (c *SenopatiClient) ListModels(ctx context.Context) (*ModelsResponse, error) {
+	resp, err := c.do(ctx, "GET", "/models", "", nil)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	var result VisionPDFResponse
+	var result ModelsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
@@ -242,32 +291,178 @@ func (c *SenopatiClient) VisionPDF(model, prompt string, pdfData []byte) (*Visio
 	return &result, nil
 }
 
-// ListModels calls the /models endpoint
-func (c *SenopatiClient) ListModels() (*ModelsResponse, error) {
-	req, err := http.NewRequest("GET", c.BaseURL+"/models", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// do sends a request, retrying retryable failures with exponential backoff
+// and jitter. The request is rebuilt for every attempt so the body can be
+// replayed. Calls fail fast with a CircuitOpenError while the breaker is open.
+// Cancelling ctx aborts the request and any pending backoff; such failures
+// are not retried and do not count against the breaker. The whole call,
+// including reading the response, is bounded by CallBudget.
+// On success the caller owns the returned response body.
+func (c *SenopatiClient) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	return c.doWith(ctx, c.HTTPClient, c.CallBudget, method, path, contentType, body)
+}
+
+// doWith is do using the given HTTP client and time budget; a zero budget
+// leaves the call bounded only by the client and ctx
+func (c *SenopatiClient) doWith(ctx context.Context, client *http.Client, budget time.Duration, method, path, contentType string, body []byte) (*http.Response, error) {
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	var deadline time.Time
+	if budget > 0 {
+		deadline = time.Now().Add(budget)
+		callCtx, cancel = context.WithDeadline(ctx, deadline)
 	}
 
-	if c.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := c.breaker.Allow(); err != nil {
+			cancel()
+			return nil, err
+		}
+
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(callCtx, method, c.BaseURL+path, reqBody)
+		if err != nil {
+			c.breaker.Release()
+			cancel()
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.APIKey)
+		}
+
+		var retryAfter time.Duration
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			c.breaker.RecordSuccess()
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		retryable := false
+		if err != nil {
+			if ctx.Err() != nil {
+				// The caller gave up; the upstream is not at fault
+				c.breaker.Release()
+				cancel()
+				return nil, ctx.Err()
+			}
+			retryable = true
+			err = fmt.Errorf("request failed: %w", err)
+		} else {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			fmt.Printf("[Senopati] Error response from %s: %s\n", path, string(respBody))
+			retryable = isRetryableStatus(resp.StatusCode)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			err = &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		}
+
+		if !retryable {
+			// A server error counts against the upstream; a rejected request
+			// says nothing about its health either way
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode >= 500 {
+				c.breaker.RecordFailure(err)
+			} else {
+				c.breaker.Release()
+			}
+			cancel()
+			return nil, err
+		}
+		c.breaker.RecordFailure(err)
+
+		delay := c.backoff(attempt, retryAfter)
+		if attempt >= c.MaxRetries || (!deadline.IsZero() && time.Until(deadline) < delay) {
+			cancel()
+			if attempt > 0 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+			}
+			return nil, err
+		}
+
+		fmt.Printf("[Senopati] %s %s failed (attempt %d/%d): %v - retrying in %s\n",
+			method, path, attempt+1, c.MaxRetries+1, err, delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			cancel()
+			return nil, ctx.Err()
+		}
 	}
+}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+// cancelOnClose releases the context of a call once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// orDefault returns value, or defaultValue when value is zero or negative
+func orDefault[T int | time.Duration](value, defaultValue T) T {
+	if value <= 0 {
+		return defaultValue
 	}
-	defer resp.Body.Close()
+	return value
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+// backoff returns the delay before retry attempt+1: exponential with equal
+// jitter, or the upstream Retry-After if that is longer
+func (c *SenopatiClient) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := c.RetryBaseDelay << attempt
+	if delay <= 0 || delay > c.RetryMaxDelay {
+		delay = c.RetryMaxDelay
 	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
 
-	var result ModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if retryAfter > delay {
+		delay = retryAfter
+		if delay > maxSenopatiRetryAfter {
+			delay = maxSenopatiRetryAfter
+		}
 	}
+	return delay
+}
 
-	return &result, nil
+// isRetryableStatus reports whether a status code signals a transient failure
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
 }
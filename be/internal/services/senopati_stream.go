@@ -0,0 +1,205 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrStreamIdle is returned when a stream sends nothing for StreamIdleTimeout
+var ErrStreamIdle = errors.New("stream idle timeout")
+
+// StreamChunk is one piece of a streamed completion. The last chunk sent on
+// a stream has Done set, or Err set if the stream failed part way.
+type StreamChunk struct {
+	Text string
+	Done bool
+	Err  error
+}
+
+// TextStream delivers a streamed completion chunk by chunk. Chunks is
+// closed after the final chunk; Close must be called if the consumer stops
+// reading early.
+type TextStream struct {
+	Chunks <-chan StreamChunk
+
+	body      io.Closer
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// Close stops the stream and releases the connection
+func (s *TextStream) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.body.Close()
+	})
+}
+
+// streamPayload covers the chunk formats Senopati may send: Ollama-style
+// NDJSON objects and OpenAI-style SSE deltas
+type streamPayload struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error"`
+	Message  *struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// GenerateTextStream calls the /generate endpoint with streaming enabled.
+// Connecting is retried like GenerateText; once chunks start arriving a
+// failure ends the stream with an error chunk instead of being retried.
+// Cancelling ctx ends the stream with ctx's error.
+func (c *SenopatiClient) GenerateTextStream(ctx context.Context, model, prompt string, temperature float64, maxTokens int) (*TextStream, error) {
+	reqBody := GenerateRequest{
+		Model:       model,
+		Prompt:      prompt,
+		Temperature: temperature,
+		MaxTokens:   maxTokens,
+		Stream:      true,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	fmt.Printf("[Senopati] POST %s/generate (stream) with model=%s, prompt length=%d, temp=%.1f\n",
+		c.BaseURL, model, len(prompt), temperature)
+
+	// A stream may run long; it is bounded by the idle timeout instead of the call budget
+	resp, err := c.doWith(ctx, c.StreamClient, 0, "POST", "/generate", "application/json", jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk)
+	stream := &TextStream{
+		Chunks: chunks,
+		body:   resp.Body,
+		stop:   make(chan struct{}),
+	}
+	go c.readStream(ctx, resp.Body, chunks, stream)
+	return stream, nil
+}
+
+// readStream parses NDJSON or SSE lines from body and sends them on chunks
+func (c *SenopatiClient) readStream(ctx context.Context, body io.Reader, chunks chan<- StreamChunk, stream *TextStream) {
+	defer close(chunks)
+	defer stream.Close()
+
+	// Abort the read if the upstream goes quiet
+	var idle bool
+	var idleMu sync.Mutex
+	timer := time.AfterFunc(c.StreamIdleTimeout, func() {
+		idleMu.Lock()
+		idle = true
+		idleMu.Unlock()
+		stream.body.Close()
+	})
+	defer timer.Stop()
+
+	send := func(chunk StreamChunk) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-stream.stop:
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		timer.Reset(c.StreamIdleTimeout)
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == ':' {
+			continue
+		}
+		// SSE framing: only data lines carry payloads
+		if bytes.HasPrefix(line, []byte("event:")) || bytes.HasPrefix(line, []byte("id:")) || bytes.HasPrefix(line, []byte("retry:")) {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte("data:")) {
+			line = bytes.TrimSpace(line[len("data:"):])
+		}
+		if string(line) == "[DONE]" {
+			c.breaker.RecordSuccess()
+			send(StreamChunk{Done: true})
+			return
+		}
+
+		var payload streamPayload
+		if err := json.Unmarshal(line, &payload); err != nil {
+			fmt.Printf("[Senopati] Skipping unparseable stream line: %s\n", string(line))
+			continue
+		}
+		if payload.Error != "" {
+			err := fmt.Errorf("stream error: %s", payload.Error)
+			c.breaker.RecordFailure(err)
+			send(StreamChunk{Err: err})
+			return
+		}
+
+		text, done := payload.Response, payload.Done
+		if payload.Message != nil {
+			text += payload.Message.Content
+		}
+		for _, choice := range payload.Choices {
+			text += choice.Text + choice.Delta.Content
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				done = true
+			}
+		}
+
+		if text != "" && !send(StreamChunk{Text: text}) {
+			return
+		}
+		if done {
+			c.breaker.RecordSuccess()
+			send(StreamChunk{Done: true})
+			return
+		}
+	}
+
+	select {
+	case <-stream.stop:
+		// The consumer closed the stream; nothing to report
+		return
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		send(StreamChunk{Err: err})
+		return
+	}
+
+	err := scanner.Err()
+	idleMu.Lock()
+	if idle {
+		err = ErrStreamIdle
+	}
+	idleMu.Unlock()
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	err = fmt.Errorf("stream interrupted: %w", err)
+	c.breaker.RecordFailure(err)
+	send(StreamChunk{Err: err})
+}
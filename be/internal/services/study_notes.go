@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"pbkk-quizlit-backend/internal/models"
+)
+
+// studyNotesQuery steers RAG retrieval towards overview material
+const studyNotesQuery = "main topics key concepts definitions important terms summary"
+
+// GenerateStudyNotes produces an outline, key points and a glossary for a document
+func (ai *AIService) GenerateStudyNotes(ctx context.Context, doc *models.Document) (*models.StudyNotes, error) {
+	if strings.TrimSpace(doc.Content) == "" {
+		return nil, fmt.Errorf("document has no content")
+	}
+	if !ai.useSenopati {
+		return nil, fmt.Errorf("no AI provider configured - Senopati is required")
+	}
+
+	ai.logger.Infof("Generating study notes for document %s", doc.ID)
+
+	content := doc.Content
+	if ai.rag != nil && ai.enableRAG {
+		content = ai.buildRAGContext(ctx, doc.UserID, doc.ID, content, documentPages(doc), studyNotesQuery)
+	}
+
+	// Keep the prompt within the same budget as quiz generation
+	if len(content) > maxSenopatiContentLength {
+		content = content[:maxSenopatiContentLength] + "\n[Content truncated due to size...]"
+	}
+
+	model := ai.selectSenopatiModel(ctx)
+	resp, err := ai.generateText(ctx, doc.UserID, model, ai.buildStudyNotesPrompt(content, doc.Title), 0.3, 4000)
+	if err != nil {
+		return nil, fmt.Errorf("senopati API error: %w", err)
+	}
+
+	notes, err := ai.parseStudyNotesResponse(resp.Response)
+	if err != nil {
+		ai.logger.Errorf("Failed to parse study notes response: %v", err)
+		return nil, err
+	}
+
+	notes.DocumentID = doc.ID
+	notes.Title = doc.Title
+	notes.GeneratedAt = time.Now()
+
+	ai.logger.Infof("Generated study notes with %d sections, %d key points, %d glossary terms",
+		len(notes.Outline), len(notes.KeyPoints), len(notes.Glossary))
+	return notes, nil
+}
+
+func (ai *AIService) buildStudyNotesPrompt(content, title string) string {
+	return fmt.Sprintf(`Create structured study notes for a student based on the following content.
+
+Title: %s
+
+Content:
+%s
+
+Requirements:
+- "outline": the main sections of the material in reading order, each with a short summary (1-2 sentences) and optional subsections
+- "key_points": 5 to 10 of the most important facts or ideas a student must remember
+- "glossary": the key technical terms used in the material with a concise definition for each
+- Use ONLY information found in the content, do not invent facts
+- LANGUAGE: Write ALL notes in Bahasa Indonesia ONLY
+
+Format as a JSON OBJECT with this EXACT structure:
+{
+  "outline": [
+    {"title": "Section title", "summary": "Short summary", "subsections": [{"title": "Subsection title", "summary": "Short summary"}]}
+  ],
+  "key_points": ["Key point"],
+  "glossary": [{"term": "Term", "definition": "Definition"}]
+}
+
+Return ONLY a valid JSON object, no markdown formatting.`, title, content)
+}
+
+func (ai *AIService) parseStudyNotesResponse(response string) (*models.StudyNotes, error) {
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+
+	// Drop any chatter around the JSON object
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("no JSON object found in response")
+	}
+	response = response[start : end+1]
+
+	var notes models.StudyNotes
+	if err := json.Unmarshal([]byte(response), &notes); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	if len(notes.Outline) == 0 && len(notes.KeyPoints) == 0 && len(notes.Glossary) == 0 {
+		return nil, fmt.Errorf("study notes response was empty")
+	}
+	if notes.KeyPoints == nil {
+		notes.KeyPoints = []string{}
+	}
+	if notes.Glossary == nil {
+		notes.Glossary = []models.GlossaryTerm{}
+	}
+	if notes.Outline == nil {
+		notes.Outline = []models.NoteSection{}
+	}
+
+	return &notes, nil
+}
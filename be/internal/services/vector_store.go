@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"pbkk-quizlit-backend/internal/models"
+	"pbkk-quizlit-backend/internal/repository"
+)
+
+// VectorItem is a single chunk with its embedding
+type VectorItem struct {
+	ID         string
+	DocumentID string
+	UserID     string
+	ChunkIndex int
+	// Page and Section locate the chunk in its source document
+	Page      int
+	Section   string
+	Text      string
+	Embedding []float64
+	// Score is the similarity to the query when returned by TopK, or the
+	// fused rank score when returned by hybrid retrieval
+	Score float64
+	// VectorScore and LexicalScore are the cosine and BM25 scores behind a
+	// hybrid result; zero when the chunk was not found by that method
+	VectorScore  float64
+	LexicalScore float64
+}
+
+// VectorFilter restricts a search to a namespace. Empty fields match everything.
+type VectorFilter struct {
+	UserID      string
+	DocumentIDs []string
+}
+
+func (f VectorFilter) matches(item VectorItem) bool {
+	if f.UserID != "" && item.UserID != f.UserID {
+		return false
+	}
+	if len(f.DocumentIDs) == 0 {
+		return true
+	}
+	for _, id := range f.DocumentIDs {
+		if item.DocumentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// VectorStore stores chunk embeddings and finds the chunks nearest to a query
+type VectorStore interface {
+	// Upsert inserts or replaces items by ID
+	Upsert(ctx context.Context, items []VectorItem) error
+	// TopK returns the k items matching filter that are most similar to
+	// query, best first, with Score set
+	TopK(ctx context.Context, query []float64, k int, filter VectorFilter) ([]VectorItem, error)
+	// List returns every item matching filter
+	List(ctx context.Context, filter VectorFilter) ([]VectorItem, error)
+	// DeleteDocument removes every item of a document
+	DeleteDocument(ctx context.Context, documentID string) error
+	// ReplaceDocument atomically replaces every item of a document with
+	// items, so searches see either the old or the new chunks, never none
+	ReplaceDocument(ctx context.Context, documentID string, items []VectorItem) error
+}
+
+// MemoryVectorStore keeps embeddings in process memory. It is lost on
+// restart and is meant for development, tests and single-instance setups.
+type MemoryVectorStore struct {
+	mu    sync.RWMutex
+	items []VectorItem
+	index map[string]int
+}
+
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{index: make(map[string]int)}
+}
+
+func (vs *MemoryVectorStore) Upsert(_ context.Context, items []VectorItem) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.upsert(items)
+	return nil
+}
+
+func (vs *MemoryVectorStore) upsert(items []VectorItem) {
+	for _, item := range items {
+		if i, ok := vs.index[item.ID]; ok {
+			vs.items[i] = item
+			continue
+		}
+		vs.index[item.ID] = len(vs.items)
+		vs.items = append(vs.items, item)
+	}
+}
+
+// TopK returns top-k items by cosine similarity
+func (vs *MemoryVectorStore) TopK(_ context.Context, query []float64, k int, filter VectorFilter) ([]VectorItem, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var candidates []VectorItem
+	for _, item := range vs.items {
+		if filter.matches(item) {
+			candidates = append(candidates, item)
+		}
+	}
+	return topKByCosine(candidates, query, k), nil
+}
+
+func (vs *MemoryVectorStore) List(_ context.Context, filter VectorFilter) ([]VectorItem, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var items []VectorItem
+	for _, item := range vs.items {
+		if filter.matches(item) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (vs *MemoryVectorStore) DeleteDocument(_ context.Context, documentID string) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.deleteDocument(documentID)
+	return nil
+}
+
+func (vs *MemoryVectorStore) ReplaceDocument(_ context.Context, documentID string, items []VectorItem) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.deleteDocument(documentID)
+	vs.upsert(items)
+	return nil
+}
+
+func (vs *MemoryVectorStore) deleteDocument(documentID string) {
+	kept := vs.items[:0]
+	for _, item := range vs.items {
+		if item.DocumentID != documentID {
+			kept = append(kept, item)
+		}
+	}
+	vs.items = kept
+
+	vs.index = make(map[string]int, len(vs.items))
+	for i, item := range vs.items {
+		vs.index[item.ID] = i
+	}
+}
+
+// topKByCosine scores items against query and returns the best k
+func topKByCosine(items []VectorItem, query []float64, k int) []VectorItem {
+	scored := make([]VectorItem, 0, len(items))
+	for _, it := range items {
+		// Embeddings from a different provider cannot be compared
+		if len(it.Embedding) != len(query) {
+			continue
+		}
+		it.Score = cosine(query, it.Embedding)
+		scored = append(scored, it)
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+	if k > len(scored) {
+		k = len(scored)
+	}
+	return scored[:k]
+}
+
+// PostgresVectorStore keeps embeddings in the document_chunks table. When
+// the pgvector column is present the search runs in SQL; otherwise chunks
+// are loaded and scored in process.
+type PostgresVectorStore struct {
+	repo      *repository.ChunkRepository
+	useVector bool
+}
+
+// NewPostgresVectorStore checks that the chunk table is migrated and whether pgvector is available
+func NewPostgresVectorStore(ctx context.Context) (*PostgresVectorStore, error) {
+	repo := repository.NewChunkRepository()
+
+	exists, err := repo.TableExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("document_chunks table not found - run migrations/add_document_chunks.sql")
+	}
+
+	hasLocation, err := repo.HasLocationColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !hasLocation {
+		return nil, fmt.Errorf("document_chunks page/section columns not found - run migrations/add_chunk_locations.sql")
+	}
+
+	useVector, err := repo.HasVectorColumn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresVectorStore{repo: repo, useVector: useVector}, nil
+}
+
+// UsesPgvector reports whether searches run in SQL through pgvector
+func (vs *PostgresVectorStore) UsesPgvector() bool {
+	return vs.useVector
+}
+
+func (vs *PostgresVectorStore) Upsert(ctx context.Context, items []VectorItem) error {
+	return vs.repo.UpsertChunks(ctx, itemsToChunks(items), vs.useVector)
+}
+
+func (vs *PostgresVectorStore) ReplaceDocument(ctx context.Context, documentID string, items []VectorItem) error {
+	return vs.repo.ReplaceDocumentChunks(ctx, documentID, itemsToChunks(items), vs.useVector)
+}
+
+func itemsToChunks(items []VectorItem) []models.DocumentChunk {
+	chunks := make([]models.DocumentChunk, len(items))
+	for i, it := range items {
+		chunks[i] = models.DocumentChunk{
+			ID:         it.ID,
+			DocumentID: it.DocumentID,
+			UserID:     it.UserID,
+			ChunkIndex: it.ChunkIndex,
+			Page:       it.Page,
+			Section:    it.Section,
+			Content:    it.Text,
+			Embedding:  it.Embedding,
+		}
+	}
+	return chunks
+}
+
+func (vs *PostgresVectorStore) TopK(ctx context.Context, query []float64, k int, filter VectorFilter) ([]VectorItem, error) {
+	if vs.useVector {
+		chunks, err := vs.repo.SearchChunks(ctx, query, k, filter.UserID, filter.DocumentIDs)
+		if err != nil {
+			return nil, err
+		}
+		return chunksToItems(chunks), nil
+	}
+
+	chunks, err := vs.repo.ListChunks(ctx, filter.UserID, filter.DocumentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return topKByCosine(chunksToItems(chunks), query, k), nil
+}
+
+func (vs *PostgresVectorStore) List(ctx context.Context, filter VectorFilter) ([]VectorItem, error) {
+	chunks, err := vs.repo.ListChunks(ctx, filter.UserID, filter.DocumentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return chunksToItems(chunks), nil
+}
+
+func (vs *PostgresVectorStore) DeleteDocument(ctx context.Context, documentID string) error {
+	_, err := vs.repo.DeleteDocumentChunks(ctx, documentID)
+	return err
+}
+
+func chunksToItems(chunks []models.DocumentChunk) []VectorItem {
+	items := make([]VectorItem, len(chunks))
+	for i, ch := range chunks {
+		items[i] = VectorItem{
+			ID:         ch.ID,
+			DocumentID: ch.DocumentID,
+			UserID:     ch.UserID,
+			ChunkIndex: ch.ChunkIndex,
+			Page:       ch.Page,
+			Section:    ch.Section,
+			Text:       ch.Content,
+			Embedding:  ch.Embedding,
+			Score:      ch.Score,
+		}
+	}
+	return items
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"pbkk-quizlit-backend/internal/api"
 	"pbkk-quizlit-backend/internal/config"
+	"pbkk-quizlit-backend/internal/models"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -21,9 +22,26 @@ func main() {
 		quizMode   = flag.Bool("quiz", false, "Run as quiz HTTP server (legacy)")
 		port       = flag.String("port", "", "Server port (overrides config)")
 		uploadDir  = flag.String("upload-dir", "./uploads", "Upload directory for PDF server mode")
-		filePath   = flag.String("file", "", "Path to the PDF file to parse (CLI mode)")
-		infoOnly   = flag.Bool("info", false, "Show only PDF information without extracting text (CLI mode)")
+		filePath   = flag.String("file", "", "Path to the document (PDF, DOCX, PPTX, EPUB, TXT, MD, HTML) to parse (CLI mode)")
+		infoOnly   = flag.Bool("info", false, "Show only document information without extracting text (CLI mode)")
 		help       = flag.Bool("help", false, "Show help message")
+
+		annBench    = flag.Bool("ann-bench", false, "Benchmark the HNSW vector store against brute force and report recall")
+		annItems    = flag.Int("ann-items", 20000, "Number of synthetic embeddings for -ann-bench")
+		annDims     = flag.Int("ann-dims", 256, "Embedding dimensions for -ann-bench")
+		annQueries  = flag.Int("ann-queries", 200, "Number of queries for -ann-bench")
+		annK        = flag.Int("ann-k", 10, "Results per query for -ann-bench")
+		annEfSearch = flag.Int("ann-ef", 64, "HNSW efSearch for -ann-bench")
+
+		ragEval          = flag.String("rag-eval", "", "Path to a labeled queries JSON file; runs a RAG retrieval evaluation")
+		ragDocs          = flag.String("rag-docs", "", "Directory of documents (PDF, DOCX, PPTX, EPUB, TXT, MD, HTML) for -rag-eval")
+		ragK             = flag.Int("rag-k", 5, "Chunks retrieved per query for -rag-eval")
+		ragChunkSize     = flag.Int("rag-chunk-size", 800, "Chunk size in runes (Unicode characters) for -rag-eval")
+		ragChunkOverlap  = flag.Int("rag-chunk-overlap", 150, "Chunk overlap in runes (Unicode characters) for -rag-eval")
+		ragEmbedder      = flag.String("rag-embedder", "local", "Embedding provider for -rag-eval (local, hash, ollama, openai)")
+		ragStore         = flag.String("rag-store", "memory", "Vector store for -rag-eval (memory or hnsw)")
+		ragLexicalWeight = flag.Float64("rag-lexical-weight", 0.5, "BM25 weight in rank fusion for -rag-eval (0 disables)")
+		ragMMR           = flag.Float64("rag-mmr", 0, "MMR lambda for -rag-eval (0 disables re-ranking)")
 	)
 	flag.Parse()
 
@@ -33,6 +51,41 @@ func main() {
 		return
 	}
 
+	if *annBench {
+		err := runANNBench(annBenchOptions{
+			Items:    *annItems,
+			Dims:     *annDims,
+			Queries:  *annQueries,
+			K:        *annK,
+			EfSearch: *annEfSearch,
+		})
+		if err != nil {
+			log.Fatalf("ANN benchmark failed: %v", err)
+		}
+		return
+	}
+
+	if *ragEval != "" {
+		if *ragDocs == "" {
+			log.Fatal("-rag-eval requires -rag-docs")
+		}
+		err := runRAGEval(ragEvalOptions{
+			DocsDir:       *ragDocs,
+			QueriesPath:   *ragEval,
+			K:             *ragK,
+			ChunkSize:     *ragChunkSize,
+			ChunkOverlap:  *ragChunkOverlap,
+			Embedder:      *ragEmbedder,
+			Store:         *ragStore,
+			LexicalWeight: *ragLexicalWeight,
+			MMRLambda:     *ragMMR,
+		})
+		if err != nil {
+			log.Fatalf("RAG evaluation failed: %v", err)
+		}
+		return
+	}
+
 	// CLI mode for PDF parsing
 	if *filePath != "" {
 		runCLI(*filePath, *infoOnly)
@@ -123,7 +176,7 @@ func runServer(port, uploadDir string) {
 func runCLI(filePath string, infoOnly bool) {
 	// Validate required arguments
 	if filePath == "" {
-		fmt.Println("Error: PDF file path is required in CLI mode")
+		fmt.Println("Error: file path is required in CLI mode")
 		fmt.Println("Use -help for usage information")
 		os.Exit(1)
 	}
@@ -165,29 +218,52 @@ func showHelp() {
 	fmt.Println("  go run *.go -server            # Run only PDF parser server")
 	fmt.Println("  go run *.go -auth -port 8080   # With custom port")
 	fmt.Println()
-	fmt.Println("CLI Mode (PDF Parsing):")
-	fmt.Println("  go run *.go -file <path_to_pdf>        # Extract text from PDF")
-	fmt.Println("  go run *.go -file <path_to_pdf> -info  # Show PDF info only")
+	fmt.Println("CLI Mode (Document Parsing):")
+	fmt.Println("  go run *.go -file <path>        # Extract text from a PDF, DOCX, PPTX, EPUB, TXT, MD or HTML file")
+	fmt.Println("  go run *.go -file <path> -info  # Show document info only")
+	fmt.Println()
+	fmt.Println("Vector Index Benchmark:")
+	fmt.Println("  go run *.go -ann-bench                         # HNSW vs brute force: latency and recall@k")
+	fmt.Println("  go run *.go -ann-bench -ann-items 100000 -ann-ef 128")
+	fmt.Println()
+	fmt.Println("RAG Retrieval Evaluation:")
+	fmt.Println("  go run *.go -rag-eval queries.json -rag-docs ./docs          # recall@k, MRR and latency")
+	fmt.Println("  go run *.go -rag-eval queries.json -rag-docs ./docs -rag-chunk-size 500 -rag-mmr 0.7")
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -port string      Server port (default: from config or 8080)")
 	fmt.Println("  -upload-dir       Upload directory for PDF server mode (default: ./uploads)")
-	fmt.Println("  -file string      Path to the PDF file to parse (CLI mode)")
-	fmt.Println("  -info             Show only PDF information without extracting text (CLI mode)")
+	fmt.Println("  -file string      Path to the document to parse (CLI mode)")
+	fmt.Println("  -info             Show only document information without extracting text (CLI mode)")
 	fmt.Println("  -legacy           Force legacy mode with separate services")
 	fmt.Println("  -auth             Run as authentication HTTP server (legacy)")
 	fmt.Println("  -quiz             Run as quiz HTTP server (legacy)")
 	fmt.Println("  -server           Run as PDF parser HTTP server (legacy)")
+	fmt.Println("  -ann-bench        Benchmark the HNSW vector store (-ann-items, -ann-dims, -ann-queries, -ann-k, -ann-ef)")
+	fmt.Println("  -rag-eval file    Evaluate retrieval on labeled queries (-rag-docs, -rag-k, -rag-chunk-size,")
+	fmt.Println("                    -rag-chunk-overlap, -rag-embedder, -rag-store, -rag-lexical-weight, -rag-mmr)")
 	fmt.Println("  -help             Show this help message")
 	fmt.Println()
 	fmt.Println("Unified API Endpoints (Default Mode):")
 	fmt.Println("  GET  /health                       - Health check")
-	fmt.Println("  POST /api/v1/quizzes/upload        - Upload PDF and generate quiz")
+	fmt.Println("  POST /api/v1/quizzes/upload        - Upload PDF, DOCX, PPTX, EPUB, TXT, MD or HTML and generate quiz")
+	fmt.Println("  POST /api/v1/quizzes/upload/inspect - Describe an upload (e.g. list e-book chapters or PDF sections)")
 	fmt.Println("  POST /api/v1/quizzes/generate      - Generate quiz from text")
+	fmt.Println("  POST /api/v1/quizzes/generate/stream - Generate quiz from text (server-sent events)")
 	fmt.Println("  GET  /api/v1/quizzes/              - List all quizzes")
 	fmt.Println("  GET  /api/v1/quizzes/:id           - Get quiz by ID")
 	fmt.Println("  PUT  /api/v1/quizzes/:id           - Update quiz")
 	fmt.Println("  DELETE /api/v1/quizzes/:id         - Delete quiz")
+	fmt.Println("  POST /api/v1/quizzes/attempt/:id/remedial - Practice quiz from wrong answers")
+	fmt.Println("  POST /api/v1/documents             - Store an uploaded file as a document")
+	fmt.Println("  GET  /api/v1/documents             - List your documents")
+	fmt.Println("  GET  /api/v1/documents/:id         - Get a document with its chapters and sections")
+	fmt.Println("  GET  /api/v1/documents/:id/file    - Download the original file of a document")
+	fmt.Println("  POST /api/v1/documents/:id/quizzes - Generate another quiz from a stored document")
+	fmt.Println("  POST /api/v1/documents/:id/summary - Generate study notes for a document")
+	fmt.Println("  DELETE /api/v1/documents/:id      - Delete a document, its retrieval chunks and chat history")
+	fmt.Println("  POST /api/v1/documents/:id/chat    - Ask a question about a document (answers cite pages)")
+	fmt.Println("  GET  /api/v1/documents/:id/chat    - Get chat history for a document")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  # Start unified server (recommended)")
@@ -217,16 +293,35 @@ func showPDFInfo(parser *PDFParser, filePath string) error {
 		return err
 	}
 
-	fmt.Println("PDF Information")
-	fmt.Println("===============")
+	fmt.Println("Document Information")
+	fmt.Println("====================")
 	fmt.Printf("File Name:    %s\n", info.FileName)
 	fmt.Printf("File Path:    %s\n", info.FilePath)
 	fmt.Printf("File Size:    %s\n", info.FormatFileSize())
 	fmt.Printf("Page Count:   %d\n", info.PageCount)
 
+	if len(info.Sections) > 0 {
+		fmt.Println("\nSections")
+		fmt.Println("--------")
+		printSections(info.Sections)
+	}
+
 	return nil
 }
 
+// printSections prints an outline section tree, indenting subsections
+func printSections(sections []models.DocumentSection) {
+	for _, section := range sections {
+		indent := strings.Repeat("  ", section.Level-1)
+		if section.StartPage == section.EndPage {
+			fmt.Printf("%s%d. %s (page %d)\n", indent, section.Number, section.Title, section.StartPage)
+		} else {
+			fmt.Printf("%s%d. %s (pages %d-%d)\n", indent, section.Number, section.Title, section.StartPage, section.EndPage)
+		}
+		printSections(section.Children)
+	}
+}
+
 func extractAndDisplayText(parser *PDFParser, filePath string) error {
 	// Validate file first
 	validator := NewFileValidator()
@@ -236,8 +331,8 @@ func extractAndDisplayText(parser *PDFParser, filePath string) error {
 		return err
 	}
 
-	// First show PDF info
-	fmt.Println("Processing PDF...")
+	// First show document info
+	fmt.Println("Processing document...")
 	err := showPDFInfo(parser, filePath)
 	if err != nil {
 		return err
@@ -0,0 +1,4 @@
+-- Record where each RAG chunk came from: the 1-based source page (0 when
+-- unknown) and the heading it falls under
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS page INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS section TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Conversation history for "ask your document" chat, per user and document
+CREATE TABLE IF NOT EXISTS document_chat_messages (
+    id TEXT PRIMARY KEY,
+    document_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content TEXT NOT NULL,
+    citations JSONB NOT NULL DEFAULT '[]',
+    refused BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_chat_messages_conversation
+    ON document_chat_messages(document_id, user_id, created_at);
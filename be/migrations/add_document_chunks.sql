@@ -0,0 +1,16 @@
+-- Persistent storage for RAG chunks and their embeddings
+-- Embeddings are stored as a plain array and scored in the application.
+-- Run add_document_chunks_pgvector.sql as well to search inside Postgres.
+
+CREATE TABLE IF NOT EXISTS document_chunks (
+    id TEXT PRIMARY KEY,
+    document_id TEXT NOT NULL,
+    user_id TEXT NOT NULL DEFAULT '',
+    chunk_index INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    embedding DOUBLE PRECISION[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_chunks_document_id ON document_chunks(document_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunks_user_id ON document_chunks(user_id);
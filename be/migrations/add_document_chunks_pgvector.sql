@@ -0,0 +1,13 @@
+-- Optional: nearest-neighbour search with pgvector
+-- Requires the pgvector extension. The backend detects the embedding_vec
+-- column at startup and orders by cosine distance in SQL when it exists.
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+ALTER TABLE document_chunks
+ADD COLUMN IF NOT EXISTS embedding_vec vector;
+
+-- Backfill rows written before the column existed
+UPDATE document_chunks
+SET embedding_vec = embedding::real[]::vector
+WHERE embedding_vec IS NULL;
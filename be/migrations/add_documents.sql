@@ -0,0 +1,39 @@
+-- Store uploaded documents so more quizzes can be generated without a re-upload
+-- file_data holds the original upload and is NULL for pasted text; pages and
+-- sections hold the extracted text page by page and the PDF outline
+
+CREATE TABLE IF NOT EXISTS documents (
+    id UUID PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    file_size BIGINT NOT NULL DEFAULT 0,
+    file_data BYTEA,
+    sha256 TEXT NOT NULL,
+    content TEXT NOT NULL,
+    pages JSONB NOT NULL DEFAULT '[]',
+    sections JSONB NOT NULL DEFAULT '[]',
+    page_count INTEGER NOT NULL DEFAULT 0,
+    study_notes JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The same file uploaded twice by a user is stored once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_user_sha256 ON documents(user_id, sha256);
+CREATE INDEX IF NOT EXISTS idx_documents_user_created ON documents(user_id, created_at DESC);
+
+-- Quizzes of a deleted document are kept; documents tracked in memory before
+-- this migration no longer exist, so existing rows are not checked
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_quizzes_document_id') THEN
+        ALTER TABLE quizzes
+        ADD CONSTRAINT fk_quizzes_document_id FOREIGN KEY (document_id)
+        REFERENCES documents(id) ON DELETE SET NULL NOT VALID;
+    END IF;
+END $$;
+
+-- The API server used to store the quiz title as pdf_filename; its quizzes
+-- are the ones linked to a document
+UPDATE quizzes SET pdf_filename = '' WHERE document_id IS NOT NULL AND pdf_filename = title;
@@ -0,0 +1,8 @@
+-- Link practice ("practice my mistakes") quizzes to the quiz and attempt they were generated from
+
+ALTER TABLE quizzes
+ADD COLUMN IF NOT EXISTS parent_quiz_id BIGINT REFERENCES quizzes(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS source_attempt_id BIGINT REFERENCES quiz_attempts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_quizzes_parent_quiz_id ON quizzes(parent_quiz_id);
+CREATE INDEX IF NOT EXISTS idx_quizzes_source_attempt_id ON quizzes(source_attempt_id);
@@ -0,0 +1,7 @@
+-- Link quizzes to the document they were generated from
+-- Documents are tracked by the API server; quizzes created before this migration have no document
+
+ALTER TABLE quizzes
+ADD COLUMN IF NOT EXISTS document_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_quizzes_document_id ON quizzes(document_id);
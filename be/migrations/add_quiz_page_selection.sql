@@ -0,0 +1,6 @@
+-- Record the part of a PDF a quiz was generated from
+-- Quizzes generated from the whole document have no selection
+
+ALTER TABLE quizzes
+ADD COLUMN IF NOT EXISTS selected_pages TEXT,
+ADD COLUMN IF NOT EXISTS selected_sections TEXT[];
@@ -1,12 +1,16 @@
 package main
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"pbkk-quizlit-backend/internal/extract"
+	"pbkk-quizlit-backend/internal/models"
+
 	"github.com/ledongthuc/pdf"
 )
 
@@ -18,79 +22,40 @@ func NewPDFParser() *PDFParser {
 	return &PDFParser{}
 }
 
-// ExtractTextFromFile extracts text from a PDF file
+// ExtractTextFromFile extracts the text of a file in any supported format,
+// marking where each page after the first starts
 func (p *PDFParser) ExtractTextFromFile(filePath string) (string, error) {
 	// Validate file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return "", fmt.Errorf("file does not exist: %s", filePath)
 	}
 
-	// Validate file extension
-	if !p.isPDFFile(filePath) {
-		return "", errors.New("file is not a PDF")
-	}
-
-	// Open the PDF file
-	file, reader, err := pdf.Open(filePath)
+	doc, err := extract.ExtractFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open PDF file: %w", err)
+		return "", fmt.Errorf("failed to extract file: %w", err)
 	}
-	defer file.Close()
 
-	// Extract text from all pages
 	var textBuilder strings.Builder
-	totalPages := reader.NumPage()
-
-	for pageNum := 1; pageNum <= totalPages; pageNum++ {
-		page := reader.Page(pageNum)
-		if page.V.IsNull() {
-			continue
-		}
-
-		// Extract text from the current page
-		pageText, err := page.GetPlainText(nil)
-		if err != nil {
-			fmt.Printf("Warning: failed to extract text from page %d: %v\n", pageNum, err)
-			continue
-		}
-
+	for i, page := range doc.Pages {
 		// Add page separator and text
-		if pageNum > 1 {
-			textBuilder.WriteString("\n--- Page ")
-			textBuilder.WriteString(fmt.Sprintf("%d", pageNum))
-			textBuilder.WriteString(" ---\n")
+		if i > 0 {
+			textBuilder.WriteString(fmt.Sprintf("\n--- Page %d ---\n", page.Number))
 		}
-		textBuilder.WriteString(pageText)
+		textBuilder.WriteString(page.Text)
 		textBuilder.WriteString("\n")
 	}
 
-	extractedText := textBuilder.String()
-	if strings.TrimSpace(extractedText) == "" {
-		return "", errors.New("no text could be extracted from the PDF")
-	}
-
-	return extractedText, nil
+	return textBuilder.String(), nil
 }
 
-// GetPDFInfo returns basic information about the PDF
+// GetPDFInfo returns basic information about a file in any supported
+// format. PDFs are only opened, other formats have to be extracted.
 func (p *PDFParser) GetPDFInfo(filePath string) (*PDFInfo, error) {
 	// Validate file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("file does not exist: %s", filePath)
 	}
 
-	// Validate file extension
-	if !p.isPDFFile(filePath) {
-		return nil, errors.New("file is not a PDF")
-	}
-
-	// Open the PDF file
-	file, reader, err := pdf.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open PDF file: %w", err)
-	}
-	defer file.Close()
-
 	// Get file info
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -98,27 +63,54 @@ func (p *PDFParser) GetPDFInfo(filePath string) (*PDFInfo, error) {
 	}
 
 	info := &PDFInfo{
-		FileName:  filepath.Base(filePath),
-		FilePath:  filePath,
-		FileSize:  fileInfo.Size(),
-		PageCount: reader.NumPage(),
+		FileName: filepath.Base(filePath),
+		FilePath: filePath,
+		FileSize: fileInfo.Size(),
+	}
+
+	if !p.isPDFContent(filePath) {
+		doc, err := extract.ExtractFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract file: %w", err)
+		}
+		info.PageCount = doc.Metadata.PageCount
+		info.Sections = doc.Sections
+		return info, nil
+	}
+
+	// Open the PDF file
+	file, reader, err := pdf.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
 	}
+	defer file.Close()
 
+	info.PageCount = reader.NumPage()
+	info.Sections = extract.ReadPDFOutline(reader)
 	return info, nil
 }
 
-// isPDFFile checks if the file has a PDF extension
-func (p *PDFParser) isPDFFile(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	return ext == ".pdf"
+// isPDFContent checks if the file starts with the PDF signature
+func (p *PDFParser) isPDFContent(filePath string) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 5)
+	n, _ := io.ReadFull(f, header)
+	return bytes.HasPrefix(header[:n], []byte("%PDF-"))
 }
 
-// PDFInfo contains basic information about a PDF file
+// PDFInfo contains basic information about a document file
 type PDFInfo struct {
 	FileName  string `json:"file_name"`
 	FilePath  string `json:"file_path"`
 	FileSize  int64  `json:"file_size"`
 	PageCount int    `json:"page_count"`
+	// Sections is the outline (bookmarks) of a PDF as a section tree
+	Sections []models.DocumentSection `json:"sections,omitempty"`
 }
 
 // FormatFileSize returns a human-readable file size
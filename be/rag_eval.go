@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pbkk-quizlit-backend/internal/config"
+	"pbkk-quizlit-backend/internal/extract"
+	"pbkk-quizlit-backend/internal/models"
+	"pbkk-quizlit-backend/internal/services"
+)
+
+// ragEvalOptions configures a retrieval evaluation run
+type ragEvalOptions struct {
+	DocsDir       string
+	QueriesPath   string
+	K             int
+	ChunkSize     int
+	ChunkOverlap  int
+	Embedder      string
+	Store         string
+	LexicalWeight float64
+	MMRLambda     float64
+}
+
+// ragEvalQuery is one labeled query. A retrieved chunk counts as relevant
+// when it is on one of ExpectedPages of Document or contains one of
+// ExpectedPassages.
+type ragEvalQuery struct {
+	Query string `json:"query"`
+	// Document restricts the search to one file of the docs directory; it
+	// is required with ExpectedPages, which are pages of that file
+	Document         string   `json:"document,omitempty"`
+	ExpectedPages    []int    `json:"expected_pages,omitempty"`
+	ExpectedPassages []string `json:"expected_passages,omitempty"`
+}
+
+// runRAGEval indexes every supported document in DocsDir, runs the labeled
+// queries through RAGService and reports recall@k, MRR and latency
+func runRAGEval(opts ragEvalOptions) error {
+	ctx := context.Background()
+	if opts.K < 1 {
+		return fmt.Errorf("-rag-k must be at least 1, got %d", opts.K)
+	}
+
+	queries, err := loadRAGEvalQueries(opts.QueriesPath)
+	if err != nil {
+		return err
+	}
+
+	// Remote embedders are configured through the usual EMBEDDING_* variables
+	cfg := config.Load()
+	embedder, err := services.NewEmbeddingProvider(services.EmbeddingOptions{
+		Provider:  opts.Embedder,
+		BaseURL:   cfg.EmbeddingBaseURL,
+		Model:     cfg.EmbeddingModel,
+		APIKey:    cfg.EmbeddingAPIKey,
+		BatchSize: cfg.EmbeddingBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	var store services.VectorStore
+	switch opts.Store {
+	case "memory":
+		store = services.NewMemoryVectorStore()
+	case "hnsw":
+		store = services.NewHNSWVectorStore(cfg.HNSWM, cfg.HNSWEfConstruction, cfg.HNSWEfSearch)
+	default:
+		return fmt.Errorf("unknown store %q (use memory or hnsw)", opts.Store)
+	}
+
+	rag := services.NewRAGServiceWithStore(embedder, store)
+	rag.SetChunking(opts.ChunkSize, opts.ChunkOverlap)
+	rag.SetLexicalWeight(opts.LexicalWeight)
+
+	fmt.Println("RAG Evaluation")
+	fmt.Println("==============")
+	fmt.Printf("Documents:  %s\n", opts.DocsDir)
+	fmt.Printf("Queries:    %s (%d)\n", opts.QueriesPath, len(queries))
+	fmt.Printf("Settings:   k=%d chunk=%d overlap=%d embedder=%s store=%s lexical=%.2f mmr=%.2f\n\n",
+		opts.K, opts.ChunkSize, opts.ChunkOverlap, opts.Embedder, opts.Store, opts.LexicalWeight, opts.MMRLambda)
+
+	docs, err := loadRAGEvalDocuments(opts.DocsDir)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for _, name := range sortedKeys(docs) {
+		if err := rag.BuildIndex(ctx, "", name, docs[name]); err != nil {
+			return fmt.Errorf("failed to index %s: %w", name, err)
+		}
+	}
+	indexTime := time.Since(start)
+	chunks, _ := store.List(ctx, services.VectorFilter{})
+	fmt.Printf("Indexed %d documents into %d chunks in %s\n\n", len(docs), len(chunks), indexTime.Round(time.Millisecond))
+
+	var recallSum, rrSum float64
+	var latencies []time.Duration
+	for i, q := range queries {
+		var filter services.VectorFilter
+		if q.Document != "" {
+			if _, ok := docs[q.Document]; !ok {
+				return fmt.Errorf("query %d: unknown document %q", i+1, q.Document)
+			}
+			filter.DocumentIDs = []string{q.Document}
+		}
+
+		start := time.Now()
+		results, err := rag.Retrieve(ctx, q.Query, opts.K, services.RetrieveOptions{Filter: filter, MMRLambda: opts.MMRLambda})
+		latencies = append(latencies, time.Since(start))
+		if err != nil {
+			return fmt.Errorf("query %d: %w", i+1, err)
+		}
+
+		recall, rank := scoreRAGEvalQuery(q, results)
+		recallSum += recall
+		if rank > 0 {
+			rrSum += 1 / float64(rank)
+		}
+
+		status := "miss"
+		if rank > 0 {
+			status = fmt.Sprintf("rank %d", rank)
+		}
+		fmt.Printf("  [%-7s] recall %.2f  %s\n", status, recall, q.Query)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, d := range latencies {
+		total += d
+	}
+	n := len(queries)
+
+	fmt.Println()
+	fmt.Println("Results")
+	fmt.Println("=======")
+	fmt.Printf("Recall@%d:        %.3f\n", opts.K, recallSum/float64(n))
+	fmt.Printf("MRR:             %.3f\n", rrSum/float64(n))
+	fmt.Printf("Latency avg:     %s\n", total/time.Duration(n))
+	fmt.Printf("Latency p95:     %s\n", latencies[n*95/100])
+	return nil
+}
+
+// scoreRAGEvalQuery returns the share of expected pages and passages found in
+// results, and the 1-based rank of the first relevant result (0 if none)
+func scoreRAGEvalQuery(q ragEvalQuery, results []services.VectorItem) (float64, int) {
+	rank := 0
+	found := 0
+	for _, page := range q.ExpectedPages {
+		for _, r := range results {
+			if ragEvalOnPage(q, r, page) {
+				found++
+				break
+			}
+		}
+	}
+	for _, passage := range q.ExpectedPassages {
+		for _, r := range results {
+			if ragEvalContains(r.Text, passage) {
+				found++
+				break
+			}
+		}
+	}
+	for i, r := range results {
+		if ragEvalRelevant(q, r) {
+			rank = i + 1
+			break
+		}
+	}
+
+	expected := len(q.ExpectedPages) + len(q.ExpectedPassages)
+	if expected == 0 {
+		return 0, rank
+	}
+	return float64(found) / float64(expected), rank
+}
+
+func ragEvalRelevant(q ragEvalQuery, r services.VectorItem) bool {
+	for _, page := range q.ExpectedPages {
+		if ragEvalOnPage(q, r, page) {
+			return true
+		}
+	}
+	for _, passage := range q.ExpectedPassages {
+		if ragEvalContains(r.Text, passage) {
+			return true
+		}
+	}
+	return false
+}
+
+// ragEvalOnPage reports whether r is on the given page of the query's document
+func ragEvalOnPage(q ragEvalQuery, r services.VectorItem, page int) bool {
+	return r.DocumentID == q.Document && r.Page == page
+}
+
+// ragEvalMinWindow is the fewest consecutive words of a passage that a chunk
+// must hold to match it; shorter passages must match in full
+const ragEvalMinWindow = 5
+
+// ragEvalContains reports whether chunk contains passage, ignoring case and
+// whitespace. A passage split across two chunks still matches the chunk
+// holding at least half of its words, and no fewer than ragEvalMinWindow,
+// in order.
+func ragEvalContains(chunk, passage string) bool {
+	chunkWords := strings.Fields(strings.ToLower(chunk))
+	passageWords := strings.Fields(strings.ToLower(passage))
+	if len(passageWords) == 0 {
+		return false
+	}
+	normChunk := " " + strings.Join(chunkWords, " ") + " "
+	if strings.Contains(normChunk, " "+strings.Join(passageWords, " ")+" ") {
+		return true
+	}
+
+	window := max((len(passageWords)+1)/2, ragEvalMinWindow)
+	for start := 0; start+window <= len(passageWords); start++ {
+		if strings.Contains(normChunk, " "+strings.Join(passageWords[start:start+window], " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func loadRAGEvalQueries(path string) ([]ragEvalQuery, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queries: %w", err)
+	}
+	var queries []ragEvalQuery
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("failed to parse queries: %w", err)
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries in %s", path)
+	}
+	for i, q := range queries {
+		if strings.TrimSpace(q.Query) == "" {
+			return nil, fmt.Errorf("query %d has no text", i+1)
+		}
+		if len(q.ExpectedPages) == 0 && len(q.ExpectedPassages) == 0 {
+			return nil, fmt.Errorf("query %d has no expected_pages or expected_passages", i+1)
+		}
+		if len(q.ExpectedPages) > 0 && q.Document == "" {
+			return nil, fmt.Errorf("query %d has expected_pages but no document", i+1)
+		}
+	}
+	return queries, nil
+}
+
+// loadRAGEvalDocuments extracts the pages of every supported file in dir,
+// keyed by file name
+func loadRAGEvalDocuments(dir string) (map[string][]models.DocumentPage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read documents directory: %w", err)
+	}
+
+	docs := make(map[string][]models.DocumentPage)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !extract.Supported(name) {
+			continue
+		}
+
+		doc, err := extract.ExtractFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		docs[name] = doc.Pages
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no supported documents in %s", dir)
+	}
+	return docs, nil
+}
+
+func sortedKeys(m map[string][]models.DocumentPage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
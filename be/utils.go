@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"pbkk-quizlit-backend/internal/extract"
 )
 
 // FileValidator handles file validation operations
@@ -40,12 +42,12 @@ func (v *FileValidator) ValidateFile(filePath string) error {
 	}
 
 	// Check file extension
-	if !v.isPDFFile(filePath) {
+	if !extract.Supported(filePath) {
 		ext := filepath.Ext(filePath)
 		if ext == "" {
-			return fmt.Errorf("file has no extension, expected .pdf")
+			return fmt.Errorf("file has no extension, expected one of %s", extract.SupportedFormats)
 		}
-		return fmt.Errorf("invalid file extension '%s', expected .pdf", ext)
+		return fmt.Errorf("invalid file extension '%s', expected one of %s", ext, extract.SupportedFormats)
 	}
 
 	// Check file size (limit to 20MB)
@@ -59,6 +61,11 @@ func (v *FileValidator) ValidateFile(filePath string) error {
 		return fmt.Errorf("file is empty")
 	}
 
+	// Other formats are checked by their extractor
+	if !v.isPDFFile(filePath) {
+		return nil
+	}
+
 	f, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
@@ -152,7 +159,7 @@ func (h *ErrorHandler) HandleError(err error, context string) {
 	case strings.Contains(errorMsg, "permission denied"):
 		fmt.Println("Suggestion: Check if you have permission to read the file.")
 	case strings.Contains(errorMsg, "invalid file extension"):
-		fmt.Println("Suggestion: Ensure the file has a .pdf extension.")
+		fmt.Println("Suggestion: Use one of the supported formats: " + extract.SupportedFormats + ".")
 	case strings.Contains(errorMsg, "file too large"):
 		fmt.Println("Suggestion: Try with a smaller PDF file (max 100MB).")
 	case strings.Contains(errorMsg, "directory"):